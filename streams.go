@@ -1,6 +1,22 @@
 package streams
 
-import "constraints"
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"iter"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
 
 func zero[T any]() T {
 	var t T
@@ -13,6 +29,15 @@ func Done[T any]() (T, bool) { return zero[T](), false }
 
 type Stream[T any] func() (T, bool)
 
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+type Float interface {
+	~float32 | ~float64
+}
+
 func Elements[T any, Slice ~[]T](s Slice) Stream[T] {
 	return Map(Indices[T](s), func(i int) T {
 		return s[i]
@@ -79,13 +104,20 @@ func Filter[T any](s Stream[T], f func(T) bool) Stream[T] {
 	}
 }
 
-func Range[Int constraints.Integer](a, b Int) Stream[Int] {
+// Range yields the integers from a up to, but not including, b. If a > b
+// the sequence counts down instead, so Range(5, 0) yields 5 4 3 2 1.
+func Range[Int Integer](a, b Int) Stream[Int] {
+	descending := a > b
 	return func() (Int, bool) {
 		if a == b {
 			return Done[Int]()
 		}
 		next := a
-		a++
+		if descending {
+			a--
+		} else {
+			a++
+		}
 		return More(next)
 	}
 }
@@ -135,6 +167,9 @@ func Collect[T any](s Stream[T]) []T {
 }
 
 func Take[T any](s Stream[T], i int) []T {
+	if i <= 0 {
+		return []T{}
+	}
 	return Reduce(Zip(Range(0, i), s), []T{}, func(ret []T, el Pair[int, T]) []T {
 		return append(ret, el.Second)
 	})
@@ -153,10 +188,16 @@ func Enumerate[T any, Slice ~[]T](s Slice) Stream[IndexedValue[T]] {
 		})
 }
 
+// Chain yields the elements of each stream in turn. Nil streams are treated
+// as empty.
 func Chain[T any](streams ...Stream[T]) Stream[T] {
 	i := 0
 	return func() (T, bool) {
 		for i < len(streams) {
+			if streams[i] == nil {
+				i++
+				continue
+			}
 			val, has_val := streams[i]()
 			if !has_val {
 				i++
@@ -167,3 +208,1919 @@ func Chain[T any](streams ...Stream[T]) Stream[T] {
 		return Done[T]()
 	}
 }
+
+func TakeWhile[T any](s Stream[T], pred func(T) bool) Stream[T] {
+	done := false
+	return func() (T, bool) {
+		if done {
+			return Done[T]()
+		}
+		val, has_val := s()
+		if !has_val || !pred(val) {
+			done = true
+			return Done[T]()
+		}
+		return More(val)
+	}
+}
+
+func DropWhile[T any](s Stream[T], pred func(T) bool) Stream[T] {
+	dropping := true
+	return func() (T, bool) {
+		if !dropping {
+			return s()
+		}
+		dropping = false
+		for {
+			val, has_val := s()
+			if !has_val {
+				return Done[T]()
+			}
+			if !pred(val) {
+				return More(val)
+			}
+		}
+	}
+}
+
+func Skip[T any](s Stream[T], n int) Stream[T] {
+	return func() (T, bool) {
+		for ; n > 0; n-- {
+			if _, has_val := s(); !has_val {
+				return Done[T]()
+			}
+		}
+		return s()
+	}
+}
+
+func FlatMap[A, B any](s Stream[A], f func(A) Stream[B]) Stream[B] {
+	var inner Stream[B]
+	return func() (B, bool) {
+		for {
+			if inner != nil {
+				val, has_val := inner()
+				if has_val {
+					return More(val)
+				}
+			}
+			next, has_next := s()
+			if !has_next {
+				return Done[B]()
+			}
+			inner = f(next)
+		}
+	}
+}
+
+func Flatten[T any](s Stream[Stream[T]]) Stream[T] {
+	return FlatMap(s, func(inner Stream[T]) Stream[T] {
+		return inner
+	})
+}
+
+func Distinct[T comparable](s Stream[T]) Stream[T] {
+	return DistinctBy(s, func(t T) T {
+		return t
+	})
+}
+
+func DistinctBy[T any, K comparable](s Stream[T], key func(T) K) Stream[T] {
+	seen := map[K]struct{}{}
+	return Filter(s, func(t T) bool {
+		k := key(t)
+		if _, ok := seen[k]; ok {
+			return false
+		}
+		seen[k] = struct{}{}
+		return true
+	})
+}
+
+func Dedup[T comparable](s Stream[T]) Stream[T] {
+	return DedupBy(s, func(t T) T {
+		return t
+	})
+}
+
+func DedupBy[T any, K comparable](s Stream[T], key func(T) K) Stream[T] {
+	var prev K
+	has_prev := false
+	return Filter(s, func(t T) bool {
+		k := key(t)
+		if has_prev && k == prev {
+			return false
+		}
+		prev, has_prev = k, true
+		return true
+	})
+}
+
+// Chunk groups s into slices of length size; the final chunk is shorter if
+// the stream does not divide evenly. Chunk panics if size <= 0.
+func Chunk[T any](s Stream[T], size int) Stream[[]T] {
+	if size <= 0 {
+		panic("streams: Chunk size must be positive")
+	}
+	return func() ([]T, bool) {
+		chunk := Take(s, size)
+		if len(chunk) == 0 {
+			return Done[[]T]()
+		}
+		return More(chunk)
+	}
+}
+
+// Window yields every run of size consecutive elements of s, so Window of
+// 1 2 3 4 with size 2 yields [1 2] [2 3] [3 4]. Each window is a fresh copy,
+// costing O(size) per element. Window panics if size <= 0.
+func Window[T any](s Stream[T], size int) Stream[[]T] {
+	return WindowStep(s, size, 1)
+}
+
+// WindowStep is like Window but advances step elements between windows, so
+// step == size yields non-overlapping windows and step > size skips elements
+// between them. A trailing window with fewer than size elements is dropped.
+// WindowStep panics if size or step is <= 0.
+func WindowStep[T any](s Stream[T], size, step int) Stream[[]T] {
+	if size <= 0 {
+		panic("streams: Window size must be positive")
+	}
+	if step <= 0 {
+		panic("streams: WindowStep step must be positive")
+	}
+	var buf []T
+	started := false
+	return func() ([]T, bool) {
+		if started {
+			if step < size {
+				buf = append(buf[:0], buf[step:]...)
+			} else {
+				buf = buf[:0]
+			}
+			if step > size {
+				if _, has_val := Nth(s, step-size-1); !has_val {
+					return Done[[]T]()
+				}
+			}
+		}
+		started = true
+		for len(buf) < size {
+			val, has_val := s()
+			if !has_val {
+				return Done[[]T]()
+			}
+			buf = append(buf, val)
+		}
+		return More(append([]T(nil), buf...))
+	}
+}
+
+func GroupBy[T any, K comparable](s Stream[T], key func(T) K) map[K][]T {
+	return GroupByReduce(s, key, nil, func(group []T, t T) []T {
+		return append(group, t)
+	})
+}
+
+func GroupByReduce[T any, K comparable, V any](s Stream[T], key func(T) K, init V, f func(V, T) V) map[K]V {
+	groups := map[K]V{}
+	ForEach(s, func(t T) {
+		k := key(t)
+		acc, ok := groups[k]
+		if !ok {
+			acc = init
+		}
+		groups[k] = f(acc, t)
+	})
+	return groups
+}
+
+func Partition[T any](s Stream[T], pred func(T) bool) (matched []T, rest []T) {
+	matched, rest = []T{}, []T{}
+	ForEach(s, func(t T) {
+		if pred(t) {
+			matched = append(matched, t)
+		} else {
+			rest = append(rest, t)
+		}
+	})
+	return matched, rest
+}
+
+// Scan is a lazy Reduce that yields the accumulator after each element. The
+// initial value is not emitted, so a running sum of 1 2 3 yields 1 3 6.
+func Scan[A, B any](s Stream[A], init B, f func(B, A) B) Stream[B] {
+	return Map(s, func(a A) B {
+		init = f(init, a)
+		return init
+	})
+}
+
+func Count[T any](s Stream[T]) int {
+	return Reduce(s, 0, func(n int, _ T) int {
+		return n + 1
+	})
+}
+
+func CountWhere[T any](s Stream[T], pred func(T) bool) int {
+	return Count(Filter(s, pred))
+}
+
+func Any[T any](s Stream[T], pred func(T) bool) bool {
+	found := false
+	ForEachControl(s, func(t T) Control {
+		if pred(t) {
+			found = true
+			return Break
+		}
+		return Continue
+	})
+	return found
+}
+
+func All[T any](s Stream[T], pred func(T) bool) bool {
+	return !Any(s, func(t T) bool {
+		return !pred(t)
+	})
+}
+
+func None[T any](s Stream[T], pred func(T) bool) bool {
+	return !Any(s, pred)
+}
+
+func Find[T any](s Stream[T], pred func(T) bool) (T, bool) {
+	result, found := zero[T](), false
+	ForEachControl(s, func(t T) Control {
+		if pred(t) {
+			result, found = t, true
+			return Break
+		}
+		return Continue
+	})
+	return result, found
+}
+
+func FindIndex[T any](s Stream[T], pred func(T) bool) (int, bool) {
+	i := 0
+	_, found := Find(s, func(t T) bool {
+		if pred(t) {
+			return true
+		}
+		i++
+		return false
+	})
+	if !found {
+		return -1, false
+	}
+	return i, true
+}
+
+// Min returns the smallest element of s according to less, or false if s is
+// empty. Ties resolve to the earliest element.
+func Min[T any](s Stream[T], less func(a, b T) bool) (T, bool) {
+	best, has_best := s()
+	if !has_best {
+		return Done[T]()
+	}
+	ForEach(s, func(t T) {
+		if less(t, best) {
+			best = t
+		}
+	})
+	return More(best)
+}
+
+// Max returns the largest element of s according to less, or false if s is
+// empty. Ties resolve to the earliest element.
+func Max[T any](s Stream[T], less func(a, b T) bool) (T, bool) {
+	return Min(s, func(a, b T) bool {
+		return less(b, a)
+	})
+}
+
+func MinBy[T any, K cmp.Ordered](s Stream[T], key func(T) K) (T, bool) {
+	return Min(s, func(a, b T) bool {
+		return key(a) < key(b)
+	})
+}
+
+func MaxBy[T any, K cmp.Ordered](s Stream[T], key func(T) K) (T, bool) {
+	return Max(s, func(a, b T) bool {
+		return key(a) < key(b)
+	})
+}
+
+type Number interface {
+	Integer | Float
+}
+
+func Sum[T Number](s Stream[T]) T {
+	return Reduce(s, 0, func(acc T, t T) T {
+		return acc + t
+	})
+}
+
+func Product[T Number](s Stream[T]) T {
+	return Reduce(s, 1, func(acc T, t T) T {
+		return acc * t
+	})
+}
+
+// Average returns the arithmetic mean of s, or false if s is empty.
+func Average[T Number](s Stream[T]) (float64, bool) {
+	sum, n := 0.0, 0
+	ForEach(s, func(t T) {
+		sum += float64(t)
+		n++
+	})
+	if n == 0 {
+		return Done[float64]()
+	}
+	return More(sum / float64(n))
+}
+
+func First[T any](s Stream[T]) (T, bool) {
+	return s()
+}
+
+func Last[T any](s Stream[T]) (T, bool) {
+	last, has_last := Done[T]()
+	ForEach(s, func(t T) {
+		last, has_last = More(t)
+	})
+	return last, has_last
+}
+
+func Nth[T any](s Stream[T], n int) (T, bool) {
+	if n < 0 {
+		return Done[T]()
+	}
+	return First(Skip(s, n))
+}
+
+// Reverse yields the elements of s back-to-front. It materializes the whole
+// stream on the first pull, so it cannot be used on infinite streams.
+func Reverse[T any](s Stream[T]) Stream[T] {
+	var buf []T
+	return func() (T, bool) {
+		if buf == nil {
+			buf = Collect(s)
+		}
+		if len(buf) == 0 {
+			return Done[T]()
+		}
+		last := buf[len(buf)-1]
+		buf = buf[:len(buf)-1]
+		return More(last)
+	}
+}
+
+// deferred postpones calling f until the first pull of the returned stream.
+func deferred[T any](f func() Stream[T]) Stream[T] {
+	var s Stream[T]
+	return func() (T, bool) {
+		if s == nil {
+			s = f()
+		}
+		return s()
+	}
+}
+
+// Sorted yields the elements of s ordered by less. It materializes and sorts
+// the whole stream on the first pull, using O(n) memory.
+func Sorted[T any](s Stream[T], less func(a, b T) bool) Stream[T] {
+	return deferred(func() Stream[T] {
+		buf := Collect(s)
+		sort.Slice(buf, func(i, j int) bool {
+			return less(buf[i], buf[j])
+		})
+		return Elements(buf)
+	})
+}
+
+// SortedStable is like Sorted but keeps equal elements in their original
+// order.
+func SortedStable[T any](s Stream[T], less func(a, b T) bool) Stream[T] {
+	return deferred(func() Stream[T] {
+		buf := Collect(s)
+		sort.SliceStable(buf, func(i, j int) bool {
+			return less(buf[i], buf[j])
+		})
+		return Elements(buf)
+	})
+}
+
+// Tee splits s into two streams that each yield the full sequence. Elements
+// read by one branch but not yet by the other are buffered.
+func Tee[T any](s Stream[T]) (Stream[T], Stream[T]) {
+	var buf []T
+	leader := 0
+	branch := func(id int) Stream[T] {
+		return func() (T, bool) {
+			if len(buf) > 0 && leader != id {
+				next := buf[0]
+				buf = buf[1:]
+				return More(next)
+			}
+			val, has_val := s()
+			if !has_val {
+				return Done[T]()
+			}
+			buf = append(buf, val)
+			leader = id
+			return More(val)
+		}
+	}
+	return branch(0), branch(1)
+}
+
+func Merge[T any](a, b Stream[T]) Stream[T] {
+	return RoundRobin(a, b)
+}
+
+func RoundRobin[T any](streams ...Stream[T]) Stream[T] {
+	active := append([]Stream[T](nil), streams...)
+	i := 0
+	return func() (T, bool) {
+		for len(active) > 0 {
+			val, has_val := active[i]()
+			if !has_val {
+				active = append(active[:i], active[i+1:]...)
+				if i == len(active) {
+					i = 0
+				}
+				continue
+			}
+			i = (i + 1) % len(active)
+			return More(val)
+		}
+		return Done[T]()
+	}
+}
+
+type Triple[A, B, C any] struct {
+	First  A
+	Second B
+	Third  C
+}
+
+func Zip3[A, B, C any](a Stream[A], b Stream[B], c Stream[C]) Stream[Triple[A, B, C]] {
+	return ZipWith(Zip(a, b), c, func(p Pair[A, B], next_c C) Triple[A, B, C] {
+		return Triple[A, B, C]{p.First, p.Second, next_c}
+	})
+}
+
+func ZipWith[A, B, C any](a Stream[A], b Stream[B], f func(A, B) C) Stream[C] {
+	return func() (C, bool) {
+		next_a, has_next_a := a()
+		if !has_next_a {
+			return Done[C]()
+		}
+		next_b, has_next_b := b()
+		if !has_next_b {
+			return Done[C]()
+		}
+		return More(f(next_a, next_b))
+	}
+}
+
+func Unzip[A, B any](s Stream[Pair[A, B]]) ([]A, []B) {
+	as, bs := []A{}, []B{}
+	ForEach(s, func(p Pair[A, B]) {
+		as = append(as, p.First)
+		bs = append(bs, p.Second)
+	})
+	return as, bs
+}
+
+func Repeat[T any](val T) Stream[T] {
+	return Infinite(func() T {
+		return val
+	})
+}
+
+// Cycle yields the elements of s, then replays them forever. The elements are
+// buffered on the first pass. Cycle of an empty stream is empty.
+func Cycle[T any](s Stream[T]) Stream[T] {
+	var buf []T
+	source_done := false
+	i := 0
+	return func() (T, bool) {
+		if !source_done {
+			val, has_val := s()
+			if has_val {
+				buf = append(buf, val)
+				return More(val)
+			}
+			source_done = true
+		}
+		if len(buf) == 0 {
+			return Done[T]()
+		}
+		next := buf[i]
+		i = (i + 1) % len(buf)
+		return More(next)
+	}
+}
+
+func Of[T any](vals ...T) Stream[T] {
+	return Elements(vals)
+}
+
+func Single[T any](v T) Stream[T] {
+	return Of(v)
+}
+
+func Empty[T any]() Stream[T] {
+	return Done[T]
+}
+
+// StepBy yields the first element of s and then every step-th element after
+// it. StepBy panics if step <= 0.
+func StepBy[T any](s Stream[T], step int) Stream[T] {
+	if step <= 0 {
+		panic("streams: StepBy step must be positive")
+	}
+	first := true
+	return func() (T, bool) {
+		if first {
+			first = false
+			return s()
+		}
+		return Nth(s, step-1)
+	}
+}
+
+func Intersperse[T any](s Stream[T], sep T) Stream[T] {
+	started := false
+	var pending T
+	has_pending := false
+	return func() (T, bool) {
+		if has_pending {
+			has_pending = false
+			return More(pending)
+		}
+		val, has_val := s()
+		if !has_val {
+			return Done[T]()
+		}
+		if !started {
+			started = true
+			return More(val)
+		}
+		pending, has_pending = val, true
+		return More(sep)
+	}
+}
+
+// ToMap collects s into a map using the key/value pairs returned by f. When
+// keys collide, the last element wins.
+func ToMap[T any, K comparable, V any](s Stream[T], f func(T) (K, V)) map[K]V {
+	m := map[K]V{}
+	ForEach(s, func(t T) {
+		k, v := f(t)
+		m[k] = v
+	})
+	return m
+}
+
+func PairsToMap[K comparable, V any](s Stream[Pair[K, V]]) map[K]V {
+	return ToMap(s, func(p Pair[K, V]) (K, V) {
+		return p.First, p.Second
+	})
+}
+
+// Keys yields the keys of m, snapshotted when Keys is called. As with ranging
+// over a map, the order is unspecified.
+func Keys[K comparable, V any](m map[K]V) Stream[K] {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return Elements(keys)
+}
+
+// Values yields the values of m, snapshotted when Values is called, in
+// unspecified order.
+func Values[K comparable, V any](m map[K]V) Stream[V] {
+	return Map(Entries(m), func(p Pair[K, V]) V {
+		return p.Second
+	})
+}
+
+// Entries yields the key/value pairs of m, snapshotted when Entries is
+// called, in unspecified order.
+func Entries[K comparable, V any](m map[K]V) Stream[Pair[K, V]] {
+	entries := make([]Pair[K, V], 0, len(m))
+	for k, v := range m {
+		entries = append(entries, Pair[K, V]{k, v})
+	}
+	return Elements(entries)
+}
+
+type Peekable[T any] struct {
+	source     Stream[T]
+	peeked     T
+	has_peeked bool
+}
+
+func NewPeekable[T any](s Stream[T]) *Peekable[T] {
+	return &Peekable[T]{source: s}
+}
+
+func (p *Peekable[T]) Peek() (T, bool) {
+	if !p.has_peeked {
+		val, has_val := p.source()
+		if !has_val {
+			return Done[T]()
+		}
+		p.peeked, p.has_peeked = val, true
+	}
+	return More(p.peeked)
+}
+
+func (p *Peekable[T]) Next() (T, bool) {
+	if p.has_peeked {
+		p.has_peeked = false
+		return More(p.peeked)
+	}
+	return p.source()
+}
+
+// Lines yields the lines of r without their trailing newlines. The returned
+// func reports any read error once the stream is done.
+func Lines(r io.Reader) (Stream[string], func() error) {
+	return FromScanner(bufio.NewScanner(r), func(b []byte) string {
+		return string(b)
+	})
+}
+
+// FromScanner yields each token of sc, passed through convert. The bytes
+// given to convert are only valid until the next pull, so convert must copy
+// them if it retains them. The returned func reports sc.Err.
+func FromScanner[T any](sc *bufio.Scanner, convert func([]byte) T) (Stream[T], func() error) {
+	return func() (T, bool) {
+		if !sc.Scan() {
+			return Done[T]()
+		}
+		return More(convert(sc.Bytes()))
+	}, sc.Err
+}
+
+// Runes yields the UTF-8 decoded runes of s. Invalid bytes decode to
+// utf8.RuneError, as when ranging over a string.
+func Runes(s string) Stream[rune] {
+	return func() (rune, bool) {
+		if len(s) == 0 {
+			return Done[rune]()
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+		return More(r)
+	}
+}
+
+func Bytes(s string) Stream[byte] {
+	return Map(Range(0, len(s)), func(i int) byte {
+		return s[i]
+	})
+}
+
+// Send drains s into c, blocking on each send until it is received.
+func Send[T any](s Stream[T], c chan<- T) {
+	ForEach(s, func(t T) {
+		c <- t
+	})
+}
+
+// SendClose is like Send but closes c once s is exhausted.
+func SendClose[T any](s Stream[T], c chan<- T) {
+	defer close(c)
+	Send(s, c)
+}
+
+// RecieveContext is like Recieve but also stops once ctx is cancelled.
+func RecieveContext[T any](ctx context.Context, c <-chan T) Stream[T] {
+	return func() (T, bool) {
+		if ctx.Err() != nil {
+			return Done[T]()
+		}
+		select {
+		case <-ctx.Done():
+			return Done[T]()
+		case val, has_val := <-c:
+			return val, has_val
+		}
+	}
+}
+
+// DecodeJSON yields each JSON value decoded from r. If the input is a single
+// top-level array, its elements are yielded one at a time; otherwise r is
+// read as a sequence of concatenated values. The returned func reports the
+// first decode error once the stream is done.
+func DecodeJSON[T any](r io.Reader) (Stream[T], func() error) {
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	var err error
+	started, in_array, done := false, false, false
+	finish := func(e error) (T, bool) {
+		if e != nil && e != io.EOF {
+			err = e
+		}
+		done = true
+		return Done[T]()
+	}
+	return func() (T, bool) {
+		if done {
+			return Done[T]()
+		}
+		if !started {
+			started = true
+			b, e := firstNonSpace(br)
+			if e != nil {
+				return finish(e)
+			}
+			if b == '[' {
+				if _, e := dec.Token(); e != nil {
+					return finish(e)
+				}
+				in_array = true
+			}
+		}
+		if in_array && !dec.More() {
+			_, e := dec.Token()
+			return finish(e)
+		}
+		var val T
+		if e := dec.Decode(&val); e != nil {
+			return finish(e)
+		}
+		return More(val)
+	}, func() error { return err }
+}
+
+// firstNonSpace returns the next non-whitespace byte of br without
+// consuming it.
+func firstNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+// DecodeCSV yields each record of r using the default csv.Reader settings.
+// Use FromCSV to configure the reader. The returned func reports the first
+// read error once the stream is done.
+func DecodeCSV(r io.Reader) (Stream[[]string], func() error) {
+	return FromCSV(csv.NewReader(r))
+}
+
+func FromCSV(cr *csv.Reader) (Stream[[]string], func() error) {
+	var err error
+	return func() ([]string, bool) {
+		if err != nil {
+			return Done[[]string]()
+		}
+		record, e := cr.Read()
+		if e != nil {
+			if e != io.EOF {
+				err = e
+			}
+			return Done[[]string]()
+		}
+		return More(record)
+	}, func() error { return err }
+}
+
+// ParMap is like Map but runs f on up to workers goroutines at once. Results
+// are yielded in input order, and the source is only pulled from the calling
+// goroutine, as far as workers elements ahead of the consumer. ParMap panics
+// if workers <= 0.
+func ParMap[A, B any](in Stream[A], workers int, f func(A) B) Stream[B] {
+	if workers <= 0 {
+		panic("streams: ParMap workers must be positive")
+	}
+	var pending []chan B
+	in_done := false
+	return func() (B, bool) {
+		for !in_done && len(pending) < workers {
+			val, has_val := in()
+			if !has_val {
+				in_done = true
+				break
+			}
+			result := make(chan B, 1)
+			go func(a A) {
+				result <- f(a)
+			}(val)
+			pending = append(pending, result)
+		}
+		if len(pending) == 0 {
+			return Done[B]()
+		}
+		next := pending[0]
+		pending = pending[1:]
+		return More(<-next)
+	}
+}
+
+// ForEachPar calls f on every element of s using a pool of workers
+// goroutines, returning once all calls have finished. The source is only
+// pulled from the calling goroutine. ForEachPar panics if workers <= 0.
+func ForEachPar[T any](s Stream[T], workers int, f func(T)) {
+	if workers <= 0 {
+		panic("streams: ForEachPar workers must be positive")
+	}
+	work := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			ForEach(Recieve(work), f)
+		}()
+	}
+	SendClose(s, work)
+	wg.Wait()
+}
+
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+func TryMap[A, B any](s Stream[A], f func(A) (B, error)) Stream[Result[B]] {
+	return Map(s, func(a A) Result[B] {
+		b, err := f(a)
+		return Result[B]{Value: b, Err: err}
+	})
+}
+
+// CollectErr collects the values of s, stopping at and returning the first
+// error.
+func CollectErr[T any](s Stream[Result[T]]) ([]T, error) {
+	ret := []T{}
+	var err error
+	ForEachControl(s, func(r Result[T]) Control {
+		if r.Err != nil {
+			err = r.Err
+			return Break
+		}
+		ret = append(ret, r.Value)
+		return Continue
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Catch yields the values of s, passing each error to handler. The handler
+// returns a replacement value and whether to yield it; if false the errored
+// element is dropped.
+func Catch[T any](s Stream[Result[T]], handler func(error) (T, bool)) Stream[T] {
+	return func() (T, bool) {
+		for {
+			r, has_r := s()
+			if !has_r {
+				return Done[T]()
+			}
+			if r.Err == nil {
+				return More(r.Value)
+			}
+			if val, keep := handler(r.Err); keep {
+				return More(val)
+			}
+		}
+	}
+}
+
+// Retry yields the results of factory(), calling factory again for a fresh
+// stream whenever an error is encountered, up to attempts calls in total.
+// Because each new stream starts over, elements yielded before the failure
+// are yielded again. Once attempts are used up the last error is yielded and
+// the stream ends. Retry panics if attempts <= 0.
+func Retry[T any](attempts int, factory func() Stream[Result[T]]) Stream[Result[T]] {
+	if attempts <= 0 {
+		panic("streams: Retry attempts must be positive")
+	}
+	var current Stream[Result[T]]
+	done := false
+	return func() (Result[T], bool) {
+		for !done {
+			if current == nil {
+				current = factory()
+				attempts--
+			}
+			r, has_r := current()
+			if !has_r {
+				done = true
+				break
+			}
+			if r.Err == nil {
+				return More(r)
+			}
+			if attempts == 0 {
+				done = true
+				return More(r)
+			}
+			current = nil
+		}
+		return Done[Result[T]]()
+	}
+}
+
+func ToSeq[T any](s Stream[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for val, has_val := s(); has_val; val, has_val = s() {
+			if !yield(val) {
+				return
+			}
+		}
+	}
+}
+
+// FromSeq pulls from seq one element at a time. The suspended seq is only
+// released once the stream is exhausted, so partially consumed streams should
+// be drained to free it.
+func FromSeq[T any](seq iter.Seq[T]) Stream[T] {
+	next, stop := iter.Pull(seq)
+	return func() (T, bool) {
+		val, has_val := next()
+		if !has_val {
+			stop()
+		}
+		return val, has_val
+	}
+}
+
+func ToSeq2[A, B any](s Stream[Pair[A, B]]) iter.Seq2[A, B] {
+	return func(yield func(A, B) bool) {
+		for p, has_p := s(); has_p; p, has_p = s() {
+			if !yield(p.First, p.Second) {
+				return
+			}
+		}
+	}
+}
+
+// FromSeq2 is like FromSeq but yields each key/value pair of seq as a Pair.
+func FromSeq2[A, B any](seq iter.Seq2[A, B]) Stream[Pair[A, B]] {
+	next, stop := iter.Pull2(seq)
+	return func() (Pair[A, B], bool) {
+		a, b, has_val := next()
+		if !has_val {
+			stop()
+			return Done[Pair[A, B]]()
+		}
+		return More(Pair[A, B]{a, b})
+	}
+}
+
+func MapIndexed[A, B any](s Stream[A], f func(i int, a A) B) Stream[B] {
+	i := 0
+	return Map(s, func(a A) B {
+		b := f(i, a)
+		i++
+		return b
+	})
+}
+
+func FilterIndexed[T any](s Stream[T], pred func(i int, t T) bool) Stream[T] {
+	i := 0
+	return Filter(s, func(t T) bool {
+		keep := pred(i, t)
+		i++
+		return keep
+	})
+}
+
+func ForEachIndexed[T any](s Stream[T], f func(i int, t T)) {
+	i := 0
+	ForEach(s, func(t T) {
+		f(i, t)
+		i++
+	})
+}
+
+func ReduceIndexed[A, B any](s Stream[A], init B, f func(i int, acc B, a A) B) B {
+	ForEachIndexed(s, func(i int, a A) {
+		init = f(i, init, a)
+	})
+	return init
+}
+
+func Pairwise[T any](s Stream[T]) Stream[Pair[T, T]] {
+	prev, has_prev := Done[T]()
+	return func() (Pair[T, T], bool) {
+		if !has_prev {
+			if prev, has_prev = s(); !has_prev {
+				return Done[Pair[T, T]]()
+			}
+		}
+		next, has_next := s()
+		if !has_next {
+			return Done[Pair[T, T]]()
+		}
+		pair := Pair[T, T]{prev, next}
+		prev = next
+		return More(pair)
+	}
+}
+
+// Differences yields the difference between each element of s and the one
+// before it.
+func Differences[T Number](s Stream[T]) Stream[T] {
+	return Map(Pairwise(s), func(p Pair[T, T]) T {
+		return p.Second - p.First
+	})
+}
+
+// CartesianProduct yields every pairing of an element of a with an element
+// of b. Since b must be replayed for each element of a, all of b is buffered
+// on the first pull.
+func CartesianProduct[A, B any](a Stream[A], b Stream[B]) Stream[Pair[A, B]] {
+	return deferred(func() Stream[Pair[A, B]] {
+		bs := Collect(b)
+		return FlatMap(a, func(first A) Stream[Pair[A, B]] {
+			return Map(Elements(bs), func(second B) Pair[A, B] {
+				return Pair[A, B]{first, second}
+			})
+		})
+	})
+}
+
+// RangeStep yields start, start+step, start+2*step, ... up to, but not
+// including, stop. A negative step counts down. RangeStep panics if step is
+// zero.
+func RangeStep[Int Integer](start, stop, step Int) Stream[Int] {
+	if step == 0 {
+		panic("streams: RangeStep step must be non-zero")
+	}
+	descending := step < 0
+	done := false
+	return func() (Int, bool) {
+		if done || (!descending && start >= stop) || (descending && start <= stop) {
+			return Done[Int]()
+		}
+		next := start
+		start += step
+		// Stop rather than wrap around if the step overflowed Int.
+		if (!descending && start < next) || (descending && start > next) {
+			done = true
+		}
+		return More(next)
+	}
+}
+
+// RangeFloat yields start + i*step for i = 0, 1, 2, ... while the value has
+// not reached stop. Computing each value from start rather than by repeated
+// addition keeps rounding error from accumulating. RangeFloat panics if step
+// is zero.
+func RangeFloat[F Float](start, stop, step F) Stream[F] {
+	if step == 0 {
+		panic("streams: RangeFloat step must be non-zero")
+	}
+	i := 0
+	return func() (F, bool) {
+		next := start + F(i)*step
+		if (step > 0 && next >= stop) || (step < 0 && next <= stop) {
+			return Done[F]()
+		}
+		i++
+		return More(next)
+	}
+}
+
+// Iterate yields seed, next(seed), next(next(seed)), ... forever. next is
+// only called once the following element is pulled, so it never runs ahead
+// of the consumer.
+func Iterate[T any](seed T, next func(T) T) Stream[T] {
+	first := true
+	return func() (T, bool) {
+		if first {
+			first = false
+		} else {
+			seed = next(seed)
+		}
+		return More(seed)
+	}
+}
+
+// Unfold repeatedly calls f with the current state, yielding the value it
+// returns and moving to the next state, until f returns false.
+func Unfold[S, T any](seed S, f func(S) (T, S, bool)) Stream[T] {
+	done := false
+	return func() (T, bool) {
+		if done {
+			return Done[T]()
+		}
+		val, next, more := f(seed)
+		if !more {
+			done = true
+			return Done[T]()
+		}
+		seed = next
+		return More(val)
+	}
+}
+
+// Memoize caches the elements of s as they are pulled and returns a factory
+// for streams over them. Each call to the factory returns a new stream that
+// starts from the first element, so the sequence can be consumed any number
+// of times while s itself is only consumed once.
+func Memoize[T any](s Stream[T]) func() Stream[T] {
+	var cache []T
+	source_done := false
+	return func() Stream[T] {
+		i := 0
+		return func() (T, bool) {
+			if i == len(cache) {
+				if source_done {
+					return Done[T]()
+				}
+				val, has_val := s()
+				if !has_val {
+					source_done = true
+					return Done[T]()
+				}
+				cache = append(cache, val)
+			}
+			i++
+			return More(cache[i-1])
+		}
+	}
+}
+
+// TakeLast returns the final n elements of s, using a ring buffer of size n.
+func TakeLast[T any](s Stream[T], n int) []T {
+	if n <= 0 {
+		return []T{}
+	}
+	ring := []T{}
+	start := 0
+	ForEach(s, func(t T) {
+		if len(ring) < n {
+			ring = append(ring, t)
+			return
+		}
+		ring[start] = t
+		start = (start + 1) % n
+	})
+	return append(ring[start:], ring[:start]...)
+}
+
+// DropLast yields all but the final n elements of s, buffering n elements
+// ahead of the consumer.
+func DropLast[T any](s Stream[T], n int) Stream[T] {
+	if n <= 0 {
+		return s
+	}
+	ring := []T{}
+	start := 0
+	return func() (T, bool) {
+		for len(ring) < n {
+			val, has_val := s()
+			if !has_val {
+				return Done[T]()
+			}
+			ring = append(ring, val)
+		}
+		val, has_val := s()
+		if !has_val {
+			return Done[T]()
+		}
+		oldest := ring[start]
+		ring[start] = val
+		start = (start + 1) % n
+		return More(oldest)
+	}
+}
+
+// SplitOn yields the runs of elements between delimiters, dropping the
+// delimiters themselves. As with strings.Split, consecutive delimiters and a
+// leading or trailing delimiter produce empty chunks. An empty stream yields
+// no chunks.
+func SplitOn[T any](s Stream[T], isDelim func(T) bool) Stream[[]T] {
+	after_delim, done := false, false
+	return func() ([]T, bool) {
+		if done {
+			return Done[[]T]()
+		}
+		chunk := []T{}
+		for {
+			val, has_val := s()
+			if !has_val {
+				done = true
+				if len(chunk) == 0 && !after_delim {
+					return Done[[]T]()
+				}
+				return More(chunk)
+			}
+			if isDelim(val) {
+				after_delim = true
+				return More(chunk)
+			}
+			chunk = append(chunk, val)
+		}
+	}
+}
+
+func ChunkBy[T any, K comparable](s Stream[T], key func(T) K) Stream[[]T] {
+	p := NewPeekable(s)
+	return func() ([]T, bool) {
+		first, has_first := p.Next()
+		if !has_first {
+			return Done[[]T]()
+		}
+		chunk := []T{first}
+		k := key(first)
+		for next, has_next := p.Peek(); has_next && key(next) == k; next, has_next = p.Peek() {
+			chunk = append(chunk, next)
+			p.Next()
+		}
+		return More(chunk)
+	}
+}
+
+func Frequencies[T comparable](s Stream[T]) map[T]int {
+	return GroupByReduce(s, func(t T) T { return t }, 0, func(n int, _ T) int {
+		return n + 1
+	})
+}
+
+// Mode returns the most common element of s and its count, or false if s is
+// empty. Ties resolve to the value that first reached the winning count.
+func Mode[T comparable](s Stream[T]) (T, int, bool) {
+	counts := map[T]int{}
+	best, best_count := zero[T](), 0
+	ForEach(s, func(t T) {
+		counts[t]++
+		if counts[t] > best_count {
+			best, best_count = t, counts[t]
+		}
+	})
+	return best, best_count, best_count > 0
+}
+
+// Pipeline wraps a Stream so that same-type operations can be chained, as in
+// Chained(Range(0, 10)).Filter(odd).Skip(1).Collect(). Operations that change
+// the element type, such as Map, remain free functions since methods cannot
+// introduce type parameters; use Stream and Chained to move between the two.
+type Pipeline[T any] Stream[T]
+
+func Chained[T any](s Stream[T]) Pipeline[T] { return Pipeline[T](s) }
+
+func (p Pipeline[T]) Stream() Stream[T] { return Stream[T](p) }
+
+func (p Pipeline[T]) Filter(f func(T) bool) Pipeline[T] { return Chained(Filter(p.Stream(), f)) }
+
+func (p Pipeline[T]) FilterNot(pred func(T) bool) Pipeline[T] {
+	return Chained(FilterNot(p.Stream(), pred))
+}
+
+func (p Pipeline[T]) TakeWhile(pred func(T) bool) Pipeline[T] {
+	return Chained(TakeWhile(p.Stream(), pred))
+}
+
+func (p Pipeline[T]) DropWhile(pred func(T) bool) Pipeline[T] {
+	return Chained(DropWhile(p.Stream(), pred))
+}
+
+func (p Pipeline[T]) Skip(n int) Pipeline[T] { return Chained(Skip(p.Stream(), n)) }
+
+func (p Pipeline[T]) StepBy(step int) Pipeline[T] { return Chained(StepBy(p.Stream(), step)) }
+
+func (p Pipeline[T]) Chain(rest ...Stream[T]) Pipeline[T] {
+	return Chained(Chain(append([]Stream[T]{p.Stream()}, rest...)...))
+}
+
+func (p Pipeline[T]) Take(n int) []T { return Take(p.Stream(), n) }
+
+func (p Pipeline[T]) Collect() []T { return Collect(p.Stream()) }
+
+func (p Pipeline[T]) ForEach(f func(T)) { ForEach(p.Stream(), f) }
+
+func (p Pipeline[T]) Count() int { return Count(p.Stream()) }
+
+func (p Pipeline[T]) First() (T, bool) { return First(p.Stream()) }
+
+func (p Pipeline[T]) Last() (T, bool) { return Last(p.Stream()) }
+
+func (p Pipeline[T]) Find(pred func(T) bool) (T, bool) { return Find(p.Stream(), pred) }
+
+func (p Pipeline[T]) Any(pred func(T) bool) bool { return Any(p.Stream(), pred) }
+
+func (p Pipeline[T]) All(pred func(T) bool) bool { return All(p.Stream(), pred) }
+
+func Equal[T comparable](a, b Stream[T]) bool {
+	return EqualBy(a, b, func(x, y T) bool {
+		return x == y
+	})
+}
+
+func EqualBy[T any](a, b Stream[T], eq func(T, T) bool) bool {
+	for {
+		next_a, has_next_a := a()
+		next_b, has_next_b := b()
+		if !has_next_a || !has_next_b {
+			return has_next_a == has_next_b
+		}
+		if !eq(next_a, next_b) {
+			return false
+		}
+	}
+}
+
+// StartsWith reports whether the leading elements of s match prefix. It pulls
+// at most as many elements from s as prefix has.
+func StartsWith[T comparable](s Stream[T], prefix Stream[T]) bool {
+	return All(prefix, func(want T) bool {
+		got, has_got := s()
+		return has_got && got == want
+	})
+}
+
+func Join(s Stream[string], sep string) string {
+	var b strings.Builder
+	ForEach(Intersperse(s, sep), func(str string) {
+		b.WriteString(str)
+	})
+	return b.String()
+}
+
+func JoinRunes(s Stream[rune]) string {
+	var b strings.Builder
+	ForEach(s, func(r rune) {
+		b.WriteRune(r)
+	})
+	return b.String()
+}
+
+// ReduceControl is like Reduce but stops once f returns Break. The
+// accumulator returned alongside Break is kept.
+func ReduceControl[A, B any](s Stream[A], init B, f func(B, A) (B, Control)) B {
+	ForEachControl(s, func(a A) Control {
+		var cntl Control
+		init, cntl = f(init, a)
+		return cntl
+	})
+	return init
+}
+
+// Buffer pulls s on a separate goroutine, running up to size elements ahead
+// of the consumer so that production and consumption overlap. The producer
+// exits once s is exhausted; call the returned func to stop it early if the
+// stream is abandoned before then. A size of 0 hands each element over
+// unbuffered. Buffer panics if size < 0.
+func Buffer[T any](s Stream[T], size int) (Stream[T], func()) {
+	if size < 0 {
+		panic("streams: Buffer size must be non-negative")
+	}
+	c := make(chan T, size)
+	stop := make(chan struct{})
+	go func() {
+		defer close(c)
+		for val, has_val := s(); has_val; val, has_val = s() {
+			select {
+			case c <- val:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() (T, bool) {
+			select {
+			case <-stop:
+				return Done[T]()
+			default:
+			}
+			val, has_val := <-c
+			return val, has_val
+		}, func() {
+			once.Do(func() { close(stop) })
+		}
+}
+
+// Throttle yields the elements of s, sleeping before each pull as needed so
+// that at least interval passes between elements. The first element is not
+// delayed.
+func Throttle[T any](s Stream[T], interval time.Duration) Stream[T] {
+	var last time.Time
+	return func() (T, bool) {
+		if !last.IsZero() {
+			time.Sleep(interval - time.Since(last))
+		}
+		val, has_val := s()
+		last = time.Now()
+		return val, has_val
+	}
+}
+
+// Span collects the leading elements of s that satisfy pred and returns them
+// along with a stream of the remaining elements, starting with the first one
+// that failed pred.
+func Span[T any](s Stream[T], pred func(T) bool) (prefix []T, rest Stream[T]) {
+	p := NewPeekable(s)
+	prefix = []T{}
+	for val, has_val := p.Peek(); has_val && pred(val); val, has_val = p.Peek() {
+		prefix = append(prefix, val)
+		p.Next()
+	}
+	return prefix, p.Next
+}
+
+// orderedHeap is a heap.Interface over items ordered by less, so that
+// items[0] is always the least element.
+type orderedHeap[T any] struct {
+	items []T
+	less  func(a, b T) bool
+}
+
+func (h *orderedHeap[T]) Len() int           { return len(h.items) }
+func (h *orderedHeap[T]) Less(i, j int) bool { return h.less(h.items[i], h.items[j]) }
+func (h *orderedHeap[T]) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *orderedHeap[T]) Push(x any)         { h.items = append(h.items, x.(T)) }
+
+func (h *orderedHeap[T]) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// TopN returns the n largest elements of s according to less, largest first.
+// Only n elements are held in memory at a time.
+func TopN[T any](s Stream[T], n int, less func(a, b T) bool) []T {
+	if n <= 0 {
+		return []T{}
+	}
+	h := &orderedHeap[T]{less: less}
+	ForEach(s, func(t T) {
+		if h.Len() < n {
+			heap.Push(h, t)
+		} else if less(h.items[0], t) {
+			h.items[0] = t
+			heap.Fix(h, 0)
+		}
+	})
+	ret := make([]T, h.Len())
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i] = heap.Pop(h).(T)
+	}
+	return ret
+}
+
+// BottomN returns the n smallest elements of s according to less, smallest
+// first.
+func BottomN[T any](s Stream[T], n int, less func(a, b T) bool) []T {
+	return TopN(s, n, func(a, b T) bool {
+		return less(b, a)
+	})
+}
+
+// MovingAverage yields the mean of each Window of s, keeping a running sum so
+// that each step is O(1). MovingAverage panics if window <= 0.
+func MovingAverage[F Float](s Stream[F], window int) Stream[F] {
+	if window <= 0 {
+		panic("streams: MovingAverage window must be positive")
+	}
+	var ring []F
+	oldest := 0
+	var sum F
+	return func() (F, bool) {
+		for len(ring) < window-1 {
+			val, has_val := s()
+			if !has_val {
+				return Done[F]()
+			}
+			ring = append(ring, val)
+			sum += val
+		}
+		val, has_val := s()
+		if !has_val {
+			return Done[F]()
+		}
+		sum += val
+		if len(ring) < window {
+			ring = append(ring, val)
+		} else {
+			sum -= ring[oldest]
+			ring[oldest] = val
+			oldest = (oldest + 1) % window
+		}
+		return More(sum / F(window))
+	}
+}
+
+// Compact drops the zero values of s.
+func Compact[T comparable](s Stream[T]) Stream[T] {
+	return Filter(s, func(t T) bool {
+		return t != zero[T]()
+	})
+}
+
+// Tap calls f on each element of s as it is pulled and yields it unchanged.
+func Tap[T any](s Stream[T], f func(T)) Stream[T] {
+	return Map(s, func(t T) T {
+		f(t)
+		return t
+	})
+}
+
+// DefaultIfEmpty yields the elements of s, or just def if s is empty.
+func DefaultIfEmpty[T any](s Stream[T], def T) Stream[T] {
+	return deferred(func() Stream[T] {
+		first, has_first := s()
+		if !has_first {
+			return Single(def)
+		}
+		return Chain(Single(first), s)
+	})
+}
+
+func Prepend[T any](s Stream[T], vals ...T) Stream[T] {
+	return Chain(Elements(vals), s)
+}
+
+func Append[T any](s Stream[T], vals ...T) Stream[T] {
+	return Chain(s, Elements(vals))
+}
+
+func WithIndex[T any](s Stream[T]) Stream[IndexedValue[T]] {
+	return MapIndexed(s, func(i int, t T) IndexedValue[T] {
+		return IndexedValue[T]{Index: i, Value: t}
+	})
+}
+
+// CollectInto appends the elements of s to dst, reusing its spare capacity.
+func CollectInto[T any](s Stream[T], dst []T) []T {
+	return Reduce(s, dst, func(ret []T, el T) []T {
+		return append(ret, el)
+	})
+}
+
+// CollectN is like Collect but preallocates room for capacity elements.
+func CollectN[T any](s Stream[T], capacity int) []T {
+	return CollectInto(s, make([]T, 0, capacity))
+}
+
+// CollectChan drains s into the returned channel from a new goroutine,
+// closing it once s is exhausted. The goroutine blocks forever if the channel
+// is not fully drained; use CollectChanContext to be able to cancel it.
+func CollectChan[T any](s Stream[T]) <-chan T {
+	return CollectChanContext(context.Background(), s)
+}
+
+// CollectChanContext is like CollectChan but stops sending, and closes the
+// channel, once ctx is cancelled.
+func CollectChanContext[T any](ctx context.Context, s Stream[T]) <-chan T {
+	c := make(chan T)
+	go func() {
+		defer close(c)
+		for val, has_val := s(); has_val; val, has_val = s() {
+			select {
+			case c <- val:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return c
+}
+
+type StatsResult[F Float] struct {
+	Count    int
+	Sum      F
+	Mean     F
+	Min      F
+	Max      F
+	Variance F
+}
+
+// Stats summarizes s in a single pass, using Welford's algorithm for the
+// mean and population variance. All fields are zero if s is empty.
+func Stats[F Float](s Stream[F]) StatsResult[F] {
+	var r StatsResult[F]
+	var m2 F
+	ForEach(s, func(x F) {
+		r.Count++
+		r.Sum += x
+		if r.Count == 1 || x < r.Min {
+			r.Min = x
+		}
+		if r.Count == 1 || x > r.Max {
+			r.Max = x
+		}
+		delta := x - r.Mean
+		r.Mean += delta / F(r.Count)
+		m2 += delta * (x - r.Mean)
+	})
+	if r.Count > 0 {
+		r.Variance = m2 / F(r.Count)
+	}
+	return r
+}
+
+// ReduceRight folds s from its last element to its first. It materializes
+// the whole stream first, so it cannot be used on infinite streams.
+func ReduceRight[A, B any](s Stream[A], init B, f func(A, B) B) B {
+	return Reduce(Reverse(s), init, func(acc B, a A) B {
+		return f(a, acc)
+	})
+}
+
+// Slice yields the elements of s at indices [start, end). Negative starts are
+// treated as zero, and an end past the end of s simply stops at its end.
+func Slice[T any](s Stream[T], start, end int) Stream[T] {
+	if start < 0 {
+		start = 0
+	}
+	return TakeStream(Skip(s, start), end-start)
+}
+
+// TakeStream is a lazy Take: it yields at most the first n elements of s and
+// never pulls s beyond them.
+func TakeStream[T any](s Stream[T], n int) Stream[T] {
+	return func() (T, bool) {
+		if n <= 0 {
+			return Done[T]()
+		}
+		n--
+		return s()
+	}
+}
+
+// ElementAtOr is like Nth but returns def when s has no element at index.
+func ElementAtOr[T any](s Stream[T], index int, def T) T {
+	if val, has_val := Nth(s, index); has_val {
+		return val
+	}
+	return def
+}
+
+func FlattenSlices[T any](s Stream[[]T]) Stream[T] {
+	return FlatMap(s, func(slice []T) Stream[T] {
+		return Elements(slice)
+	})
+}
+
+func FlatMapSlice[A, B any](s Stream[A], f func(A) []B) Stream[B] {
+	return FlattenSlices(Map(s, f))
+}
+
+func FilterNot[T any](s Stream[T], pred func(T) bool) Stream[T] {
+	return Filter(s, func(t T) bool {
+		return !pred(t)
+	})
+}
+
+// ParFilter is like Filter but evaluates pred on up to workers goroutines at
+// once, in the manner of ParMap. Elements are yielded in input order.
+// ParFilter panics if workers <= 0.
+func ParFilter[T any](s Stream[T], workers int, pred func(T) bool) Stream[T] {
+	tested := ParMap(s, workers, func(t T) Pair[T, bool] {
+		return Pair[T, bool]{t, pred(t)}
+	})
+	return Map(Filter(tested, func(p Pair[T, bool]) bool {
+		return p.Second
+	}), func(p Pair[T, bool]) T {
+		return p.First
+	})
+}
+
+// parReduceChunk is how many consecutive elements each ParReduce task folds.
+const parReduceChunk = 1024
+
+// ParReduce folds s with combine using up to workers goroutines. The stream
+// is split into consecutive chunks that are reduced independently, starting
+// from identity, and the partial results are then combined in order. This is
+// only equivalent to Reduce when combine is associative and identity is its
+// identity element; otherwise the result may differ. ParReduce panics if
+// workers <= 0.
+func ParReduce[T any](s Stream[T], workers int, identity T, combine func(T, T) T) T {
+	partials := ParMap(Chunk(s, parReduceChunk), workers, func(chunk []T) T {
+		return Reduce(Elements(chunk), identity, combine)
+	})
+	return Reduce(partials, identity, combine)
+}
+
+// SortedBy is like SortedStable but orders elements by the key extracted
+// with key.
+func SortedBy[T any, K cmp.Ordered](s Stream[T], key func(T) K) Stream[T] {
+	return SortedStable(s, func(a, b T) bool {
+		return key(a) < key(b)
+	})
+}
+
+// Sample keeps each element of s independently with the given probability,
+// drawing from rng. Sample panics if probability is outside [0, 1].
+func Sample[T any](s Stream[T], probability float64, rng *rand.Rand) Stream[T] {
+	if probability < 0 || probability > 1 {
+		panic("streams: Sample probability must be in [0, 1]")
+	}
+	return Filter(s, func(T) bool {
+		return rng.Float64() < probability
+	})
+}
+
+// Position returns the index of the first element of s satisfying pred, or
+// -1 if there is none.
+func Position[T any](s Stream[T], pred func(T) bool) int {
+	i, _ := FindIndex(s, pred)
+	return i
+}
+
+// IndexOf returns the index of the first element of s equal to target, or -1
+// if there is none.
+func IndexOf[T comparable](s Stream[T], target T) int {
+	return Position(s, func(t T) bool {
+		return t == target
+	})
+}
+
+// Drain pulls and discards every element of s, returning how many there
+// were. It is useful when s is only wanted for its side effects.
+func Drain[T any](s Stream[T]) int {
+	return Count(s)
+}
+
+// Interleave takes one element from each stream in turn, skipping streams
+// that are exhausted, until all of them are done. It is the same as
+// RoundRobin.
+func Interleave[T any](streams ...Stream[T]) Stream[T] {
+	return RoundRobin(streams...)
+}
+
+// ChainSep is like Chain but yields sep between the elements of consecutive
+// streams. Empty streams are skipped entirely, so they never produce a
+// doubled separator.
+func ChainSep[T any](sep T, streams ...Stream[T]) Stream[T] {
+	started := false
+	return FlatMap(Elements(streams), func(s Stream[T]) Stream[T] {
+		if s == nil {
+			return Empty[T]()
+		}
+		first, has_first := s()
+		if !has_first {
+			return Empty[T]()
+		}
+		if !started {
+			started = true
+			return Prepend(s, first)
+		}
+		return Prepend(s, sep, first)
+	})
+}
+
+// BatchTimed groups s into batches, yielding a batch once it holds maxSize
+// elements or maxWait has passed since its first element arrived, whichever
+// comes first. Any partial batch is yielded when s ends. Since s may block,
+// it is pulled on a separate goroutine; call the returned func to stop it if
+// the stream is abandoned early. BatchTimed panics if maxSize <= 0.
+func BatchTimed[T any](s Stream[T], maxSize int, maxWait time.Duration) (Stream[[]T], func()) {
+	if maxSize <= 0 {
+		panic("streams: BatchTimed maxSize must be positive")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c := CollectChanContext(ctx, s)
+	return func() ([]T, bool) {
+		first, has_first := <-c
+		if !has_first {
+			return Done[[]T]()
+		}
+		batch := []T{first}
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		for len(batch) < maxSize {
+			select {
+			case val, has_val := <-c:
+				if !has_val {
+					return More(batch)
+				}
+				batch = append(batch, val)
+			case <-timer.C:
+				return More(batch)
+			}
+		}
+		return More(batch)
+	}, cancel
+}
+
+// Select yields values from chans as they arrive, ending once all of them
+// are closed. Values received from the same channel keep their order.
+func Select[T any](chans ...<-chan T) Stream[T] {
+	cases := make([]reflect.SelectCase, len(chans))
+	for i, c := range chans {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)}
+	}
+	return func() (T, bool) {
+		for len(cases) > 0 {
+			i, val, has_val := reflect.Select(cases)
+			if !has_val {
+				cases = append(cases[:i], cases[i+1:]...)
+				continue
+			}
+			// A nil interface value does not survive the round trip through
+			// any, so fall back to T's zero value rather than asserting.
+			next, _ := val.Interface().(T)
+			return More(next)
+		}
+		return Done[T]()
+	}
+}
+
+// Clone returns n streams that each yield the full sequence of s, which is
+// only pulled once. Elements are buffered until every clone has read them, so
+// memory grows with the gap between the fastest and slowest clone.
+func Clone[T any](s Stream[T], n int) []Stream[T] {
+	var buf []T
+	base := 0
+	pos := make([]int, n)
+	source_done := false
+	trim := func() {
+		lowest := pos[0]
+		for _, p := range pos[1:] {
+			if p < lowest {
+				lowest = p
+			}
+		}
+		buf = buf[lowest-base:]
+		base = lowest
+	}
+	clones := make([]Stream[T], n)
+	for i := range clones {
+		clones[i] = func() (T, bool) {
+			if pos[i]-base == len(buf) {
+				if source_done {
+					return Done[T]()
+				}
+				val, has_val := s()
+				if !has_val {
+					source_done = true
+					return Done[T]()
+				}
+				buf = append(buf, val)
+			}
+			next := buf[pos[i]-base]
+			pos[i]++
+			trim()
+			return More(next)
+		}
+	}
+	return clones
+}
+
+// ZipGraceful is like Zip but never discards an element. It peeks at b
+// before pulling from a, so a is not pulled once b is exhausted, and when a
+// runs out first b's next element is still available from its Peekable.
+func ZipGraceful[A, B any](a Stream[A], b *Peekable[B]) Stream[Pair[A, B]] {
+	return func() (Pair[A, B], bool) {
+		if _, has_b := b.Peek(); !has_b {
+			return Done[Pair[A, B]]()
+		}
+		next_a, has_a := a()
+		if !has_a {
+			return Done[Pair[A, B]]()
+		}
+		next_b, _ := b.Next()
+		return More(Pair[A, B]{next_a, next_b})
+	}
+}
+
+// ZipLongest is like Zip but continues until both streams are exhausted,
+// substituting fillA or fillB for whichever stream ran out first.
+func ZipLongest[A, B any](a Stream[A], b Stream[B], fillA A, fillB B) Stream[Pair[A, B]] {
+	a_done, b_done := false, false
+	return func() (Pair[A, B], bool) {
+		next_a, next_b := fillA, fillB
+		if !a_done {
+			val, has_val := a()
+			if has_val {
+				next_a = val
+			} else {
+				a_done = true
+			}
+		}
+		if !b_done {
+			val, has_val := b()
+			if has_val {
+				next_b = val
+			} else {
+				b_done = true
+			}
+		}
+		if a_done && b_done {
+			return Done[Pair[A, B]]()
+		}
+		return More(Pair[A, B]{next_a, next_b})
+	}
+}
@@ -1,7 +1,21 @@
 package streams_test
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/JacobAlbertSchmidt/streams"
 )
@@ -18,3 +32,1954 @@ func TestStreams(t *testing.T) {
 	}
 
 }
+
+func TestFilter(t *testing.T) {
+	even := func(x int) bool { return x%2 == 0 }
+	odd := func(x int) bool { return x%2 != 0 }
+	cases := []struct {
+		name     string
+		pred     func(int) bool
+		expected []int
+	}{
+		{"rejects first", odd, []int{1, 3, 5, 7, 9}},
+		{"accepts first", even, []int{0, 2, 4, 6, 8}},
+		{"rejects all", func(int) bool { return false }, []int{}},
+		{"accepts all", func(int) bool { return true }, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+	for _, c := range cases {
+		got := streams.Collect(streams.Filter(streams.Range(0, 10), c.pred))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.expected, got)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	cases := []struct {
+		a, b     int
+		expected []int
+	}{
+		{0, 5, []int{0, 1, 2, 3, 4}},
+		{5, 0, []int{5, 4, 3, 2, 1}},
+		{3, 3, []int{}},
+		{-3, 2, []int{-3, -2, -1, 0, 1}},
+		{2, -3, []int{2, 1, 0, -1, -2}},
+	}
+	for _, c := range cases {
+		got := streams.Collect(streams.Range(c.a, c.b))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("Range(%v, %v): expected %v, got %v", c.a, c.b, c.expected, got)
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	cases := []struct {
+		n        int
+		expected []int
+	}{
+		{3, []int{0, 1, 2}},
+		{0, []int{}},
+		{-3, []int{}},
+	}
+	for _, c := range cases {
+		if got := streams.Take(streams.Iota(), c.n); !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("Take(%v): expected %v, got %v", c.n, c.expected, got)
+		}
+		if got := streams.Chained(streams.Iota()).Take(c.n); !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("Pipeline.Take(%v): expected %v, got %v", c.n, c.expected, got)
+		}
+	}
+}
+
+func TestTakeWhile(t *testing.T) {
+	got := streams.Collect(streams.TakeWhile(streams.Iota(), func(x int) bool { return x < 5 }))
+	expected := []int{0, 1, 2, 3, 4}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	source := streams.Elements([]int{1, 2, 10, 3, 4})
+	s := streams.TakeWhile(source, func(x int) bool { return x < 5 })
+	streams.Collect(s)
+	if val, has_val := s(); has_val {
+		t.Fatalf("expected Done after predicate failed, got %v", val)
+	}
+	if val, _ := source(); val != 3 {
+		t.Fatalf("expected source to resume at 3, got %v", val)
+	}
+}
+
+func TestDropWhile(t *testing.T) {
+	got := streams.Collect(streams.DropWhile(streams.Range(0, 10), func(x int) bool { return x < 3 }))
+	expected := []int{3, 4, 5, 6, 7, 8, 9}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	got = streams.Collect(streams.DropWhile(streams.Elements([]int{1, 5, 1, 2}), func(x int) bool { return x < 3 }))
+	expected = []int{5, 1, 2}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestSkip(t *testing.T) {
+	cases := []struct {
+		n        int
+		expected []int
+	}{
+		{3, []int{3, 4}},
+		{0, []int{0, 1, 2, 3, 4}},
+		{-1, []int{0, 1, 2, 3, 4}},
+		{5, []int{}},
+		{10, []int{}},
+	}
+	for _, c := range cases {
+		got := streams.Collect(streams.Skip(streams.Range(0, 5), c.n))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("Skip(%v): expected %v, got %v", c.n, c.expected, got)
+		}
+	}
+}
+
+func TestFlatMap(t *testing.T) {
+	got := streams.Collect(streams.FlatMap(streams.Range(0, 4), func(n int) streams.Stream[int] {
+		return streams.Range(0, n)
+	}))
+	expected := []int{0, 0, 1, 0, 1, 2}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	got = streams.Collect(streams.FlatMap(streams.Range(0, 0), func(n int) streams.Stream[int] {
+		return streams.Range(0, n)
+	}))
+	if len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	nested := streams.Elements([]streams.Stream[int]{
+		streams.Range(0, 0),
+		streams.Range(0, 2),
+		streams.Range(0, 0),
+		streams.Range(5, 7),
+		streams.Range(0, 0),
+	})
+	got := streams.Collect(streams.Flatten(nested))
+	expected := []int{0, 1, 5, 6}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestDistinct(t *testing.T) {
+	got := streams.Collect(streams.Distinct(streams.Elements([]int{1, 2, 1, 3, 2, 4, 1})))
+	expected := []int{1, 2, 3, 4}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	words := []string{"apple", "avocado", "banana", "blueberry", "cherry"}
+	got_words := streams.Collect(streams.DistinctBy(streams.Elements(words), func(s string) byte {
+		return s[0]
+	}))
+	expected_words := []string{"apple", "banana", "cherry"}
+	if !reflect.DeepEqual(got_words, expected_words) {
+		t.Fatalf("expected %v, got %v", expected_words, got_words)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	got := streams.Collect(streams.Dedup(streams.Elements([]int{1, 1, 2, 2, 1})))
+	expected := []int{1, 2, 1}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	words := []string{"apple", "avocado", "banana", "apricot"}
+	got_words := streams.Collect(streams.DedupBy(streams.Elements(words), func(s string) byte {
+		return s[0]
+	}))
+	expected_words := []string{"apple", "banana", "apricot"}
+	if !reflect.DeepEqual(got_words, expected_words) {
+		t.Fatalf("expected %v, got %v", expected_words, got_words)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	got := streams.Collect(streams.Chunk(streams.Range(0, 7), 3))
+	expected := [][]int{{0, 1, 2}, {3, 4, 5}, {6}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	got = streams.Collect(streams.Chunk(streams.Range(0, 6), 3))
+	expected = [][]int{{0, 1, 2}, {3, 4, 5}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for size 0")
+		}
+	}()
+	streams.Chunk(streams.Range(0, 6), 0)
+}
+
+func TestWindow(t *testing.T) {
+	got := streams.Collect(streams.Window(streams.Elements([]int{1, 2, 3, 4}), 2))
+	expected := [][]int{{1, 2}, {2, 3}, {3, 4}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	got = streams.Collect(streams.Window(streams.Range(0, 2), 3))
+	if len(got) != 0 {
+		t.Fatalf("expected no windows, got %v", got)
+	}
+
+	got = streams.Collect(streams.Window(streams.Range(0, 10), math.MaxInt))
+	if len(got) != 0 {
+		t.Fatalf("expected no windows, got %v", got)
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	parity := func(x int) bool { return x%2 == 0 }
+	got := streams.GroupBy(streams.Range(0, 7), parity)
+	expected := map[bool][]int{true: {0, 2, 4, 6}, false: {1, 3, 5}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	sums := streams.GroupByReduce(streams.Range(0, 7), parity, 0, func(acc, x int) int {
+		return acc + x
+	})
+	expected_sums := map[bool]int{true: 12, false: 9}
+	if !reflect.DeepEqual(sums, expected_sums) {
+		t.Fatalf("expected %v, got %v", expected_sums, sums)
+	}
+}
+
+// counting wraps s so that every pull from the source increments *pulls.
+func counting[T any](s streams.Stream[T], pulls *int) streams.Stream[T] {
+	return func() (T, bool) {
+		*pulls++
+		return s()
+	}
+}
+
+func TestPartition(t *testing.T) {
+	pulls := 0
+	even, odd := streams.Partition(counting(streams.Range(0, 7), &pulls), func(x int) bool {
+		return x%2 == 0
+	})
+	if expected := []int{0, 2, 4, 6}; !reflect.DeepEqual(even, expected) {
+		t.Fatalf("expected %v, got %v", expected, even)
+	}
+	if expected := []int{1, 3, 5}; !reflect.DeepEqual(odd, expected) {
+		t.Fatalf("expected %v, got %v", expected, odd)
+	}
+	if pulls != 8 {
+		t.Fatalf("expected 8 pulls, got %v", pulls)
+	}
+}
+
+func TestScan(t *testing.T) {
+	sums := streams.Collect(streams.Scan(streams.Elements([]int{1, 2, 3}), 0, func(acc, x int) int {
+		return acc + x
+	}))
+	if expected := []int{1, 3, 6}; !reflect.DeepEqual(sums, expected) {
+		t.Fatalf("expected %v, got %v", expected, sums)
+	}
+
+	maxes := streams.Collect(streams.Scan(streams.Elements([]int{3, 1, 4, 1, 5}), 0, func(acc, x int) int {
+		if x > acc {
+			return x
+		}
+		return acc
+	}))
+	if expected := []int{3, 3, 4, 4, 5}; !reflect.DeepEqual(maxes, expected) {
+		t.Fatalf("expected %v, got %v", expected, maxes)
+	}
+}
+
+func TestCount(t *testing.T) {
+	if got := streams.Count(streams.Range(0, 10)); got != 10 {
+		t.Fatalf("expected %v, got %v", 10, got)
+	}
+	if got := streams.Count(streams.Range(0, 0)); got != 0 {
+		t.Fatalf("expected %v, got %v", 0, got)
+	}
+	if got := streams.CountWhere(streams.Range(0, 10), func(x int) bool { return x%3 == 0 }); got != 4 {
+		t.Fatalf("expected %v, got %v", 4, got)
+	}
+	if got := streams.CountWhere(streams.Range(0, 0), func(int) bool { return true }); got != 0 {
+		t.Fatalf("expected %v, got %v", 0, got)
+	}
+}
+
+func TestAnyAllNone(t *testing.T) {
+	if !streams.Any(streams.Iota(), func(x int) bool { return x == 100 }) {
+		t.Fatalf("expected Any to find 100")
+	}
+	if streams.All(streams.Iota(), func(x int) bool { return x < 100 }) {
+		t.Fatalf("expected All to fail at 100")
+	}
+	if streams.None(streams.Iota(), func(x int) bool { return x == 100 }) {
+		t.Fatalf("expected None to fail at 100")
+	}
+
+	empty := func() streams.Stream[int] { return streams.Range(0, 0) }
+	always := func(int) bool { return true }
+	if streams.Any(empty(), always) || !streams.All(empty(), always) || !streams.None(empty(), always) {
+		t.Fatalf("unexpected result on empty stream")
+	}
+}
+
+func TestFind(t *testing.T) {
+	source := streams.Iota()
+	val, found := streams.Find(source, func(x int) bool { return x*x > 50 })
+	if !found || val != 8 {
+		t.Fatalf("expected %v, got %v (found=%v)", 8, val, found)
+	}
+	if next, _ := source(); next != 9 {
+		t.Fatalf("expected Find to stop after the match, next was %v", next)
+	}
+
+	if _, found := streams.Find(streams.Range(0, 5), func(x int) bool { return x > 10 }); found {
+		t.Fatalf("expected no match")
+	}
+
+	i, found := streams.FindIndex(streams.Iota(), func(x int) bool { return x > 41 })
+	if !found || i != 42 {
+		t.Fatalf("expected %v, got %v (found=%v)", 42, i, found)
+	}
+	if i, found := streams.FindIndex(streams.Range(0, 5), func(x int) bool { return x > 10 }); found || i != -1 {
+		t.Fatalf("expected -1, got %v (found=%v)", i, found)
+	}
+}
+
+func TestMinMax(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	if _, ok := streams.Min(streams.Range(0, 0), less); ok {
+		t.Fatalf("expected Min of empty stream to fail")
+	}
+	if _, ok := streams.Max(streams.Range(0, 0), less); ok {
+		t.Fatalf("expected Max of empty stream to fail")
+	}
+	values := []int{3, 1, 4, 1, 5, 9, 2, 6, 5}
+	if got, _ := streams.Min(streams.Elements(values), less); got != 1 {
+		t.Fatalf("expected %v, got %v", 1, got)
+	}
+	if got, _ := streams.Max(streams.Elements(values), less); got != 9 {
+		t.Fatalf("expected %v, got %v", 9, got)
+	}
+
+	words := []string{"bb", "a", "cc", "d"}
+	length := func(s string) int { return len(s) }
+	if got, _ := streams.MinBy(streams.Elements(words), length); got != "a" {
+		t.Fatalf("expected %v, got %v", "a", got)
+	}
+	if got, _ := streams.MaxBy(streams.Elements(words), length); got != "bb" {
+		t.Fatalf("expected %v, got %v", "bb", got)
+	}
+}
+
+func TestSumProductAverage(t *testing.T) {
+	if got := streams.Sum(streams.Range(1, 5)); got != 10 {
+		t.Fatalf("expected %v, got %v", 10, got)
+	}
+	if got := streams.Product(streams.Range(1, 5)); got != 24 {
+		t.Fatalf("expected %v, got %v", 24, got)
+	}
+	if got, _ := streams.Average(streams.Range(1, 5)); got != 2.5 {
+		t.Fatalf("expected %v, got %v", 2.5, got)
+	}
+
+	floats := []float64{0.5, 1.5, 4}
+	if got := streams.Sum(streams.Elements(floats)); got != 6 {
+		t.Fatalf("expected %v, got %v", 6, got)
+	}
+	if got := streams.Product(streams.Elements(floats)); got != 3 {
+		t.Fatalf("expected %v, got %v", 3, got)
+	}
+	if got, _ := streams.Average(streams.Elements(floats)); got != 2 {
+		t.Fatalf("expected %v, got %v", 2, got)
+	}
+
+	if got := streams.Sum(streams.Range(0, 0)); got != 0 {
+		t.Fatalf("expected %v, got %v", 0, got)
+	}
+	if _, ok := streams.Average(streams.Range(0, 0)); ok {
+		t.Fatalf("expected Average of empty stream to fail")
+	}
+}
+
+func TestFirstLast(t *testing.T) {
+	pulls := 0
+	if got, ok := streams.First(counting(streams.Range(3, 10), &pulls)); !ok || got != 3 {
+		t.Fatalf("expected %v, got %v (ok=%v)", 3, got, ok)
+	}
+	if pulls != 1 {
+		t.Fatalf("expected 1 pull, got %v", pulls)
+	}
+	if got, ok := streams.Last(streams.Range(3, 10)); !ok || got != 9 {
+		t.Fatalf("expected %v, got %v (ok=%v)", 9, got, ok)
+	}
+	if _, ok := streams.First(streams.Range(0, 0)); ok {
+		t.Fatalf("expected First of empty stream to fail")
+	}
+	if _, ok := streams.Last(streams.Range(0, 0)); ok {
+		t.Fatalf("expected Last of empty stream to fail")
+	}
+}
+
+func TestNth(t *testing.T) {
+	if got, ok := streams.Nth(streams.Iota(), 1000); !ok || got != 1000 {
+		t.Fatalf("expected %v, got %v (ok=%v)", 1000, got, ok)
+	}
+	if _, ok := streams.Nth(streams.Range(0, 5), 5); ok {
+		t.Fatalf("expected Nth past the end to fail")
+	}
+	if _, ok := streams.Nth(streams.Range(0, 5), -1); ok {
+		t.Fatalf("expected negative Nth to fail")
+	}
+}
+
+func TestReverse(t *testing.T) {
+	got := streams.Collect(streams.Reverse(streams.Range(0, 5)))
+	if expected := []int{4, 3, 2, 1, 0}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Collect(streams.Reverse(streams.Range(0, 0))); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestSorted(t *testing.T) {
+	desc := func(a, b int) bool { return a > b }
+	got := streams.Collect(streams.Sorted(streams.Elements([]int{3, 1, 4, 1, 5, 9, 2}), desc))
+	if expected := []int{9, 5, 4, 3, 2, 1, 1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	type person struct {
+		Name string
+		Age  int
+	}
+	people := []person{{"ann", 30}, {"bob", 25}, {"cat", 30}, {"dan", 25}, {"eve", 30}}
+	by_age := func(a, b person) bool { return a.Age < b.Age }
+	got_people := streams.Collect(streams.SortedStable(streams.Elements(people), by_age))
+	expected_people := []person{{"bob", 25}, {"dan", 25}, {"ann", 30}, {"cat", 30}, {"eve", 30}}
+	if !reflect.DeepEqual(got_people, expected_people) {
+		t.Fatalf("expected %v, got %v", expected_people, got_people)
+	}
+}
+
+func TestTee(t *testing.T) {
+	a, b := streams.Tee(streams.Range(0, 6))
+	var got_a, got_b []int
+	for _, from_a := range []bool{true, true, false, true, false, false, false, true, true, true, false, false} {
+		if from_a {
+			val, has_val := a()
+			if !has_val {
+				t.Fatalf("a ended early")
+			}
+			got_a = append(got_a, val)
+		} else {
+			val, has_val := b()
+			if !has_val {
+				t.Fatalf("b ended early")
+			}
+			got_b = append(got_b, val)
+		}
+	}
+	expected := []int{0, 1, 2, 3, 4, 5}
+	if !reflect.DeepEqual(got_a, expected) || !reflect.DeepEqual(got_b, expected) {
+		t.Fatalf("expected %v for both, got %v and %v", expected, got_a, got_b)
+	}
+	if _, has_val := a(); has_val {
+		t.Fatalf("expected a to be done")
+	}
+	if _, has_val := b(); has_val {
+		t.Fatalf("expected b to be done")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	got := streams.Collect(streams.Merge(streams.Range(0, 2), streams.Range(10, 15)))
+	if expected := []int{0, 10, 1, 11, 12, 13, 14}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	got = streams.Collect(streams.RoundRobin(
+		streams.Range(0, 3),
+		streams.Range(10, 11),
+		streams.Range(20, 24),
+	))
+	if expected := []int{0, 10, 20, 1, 21, 2, 22, 23}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	if got := streams.Collect(streams.RoundRobin[int]()); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestZip3(t *testing.T) {
+	got := streams.Collect(streams.Zip3(
+		streams.Range(0, 5),
+		streams.Elements([]string{"a", "b", "c"}),
+		streams.Iota(),
+	))
+	expected := []streams.Triple[int, string, int]{{0, "a", 0}, {1, "b", 1}, {2, "c", 2}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestZipWith(t *testing.T) {
+	got := streams.Collect(streams.ZipWith(streams.Range(0, 10), streams.Range(100, 103), func(a, b int) int {
+		return a + b
+	}))
+	if expected := []int{100, 102, 104}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	nums, words := streams.Unzip(streams.Zip(streams.Iota(), streams.Elements([]string{"a", "b", "c"})))
+	if expected := []int{0, 1, 2}; !reflect.DeepEqual(nums, expected) {
+		t.Fatalf("expected %v, got %v", expected, nums)
+	}
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(words, expected) {
+		t.Fatalf("expected %v, got %v", expected, words)
+	}
+	if len(nums) != len(words) {
+		t.Fatalf("expected equal lengths, got %v and %v", len(nums), len(words))
+	}
+}
+
+func TestRepeatCycle(t *testing.T) {
+	if got, expected := streams.Take(streams.Repeat("x"), 3), []string{"x", "x", "x"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Take(streams.Cycle(streams.Range(0, 3)), 8), []int{0, 1, 2, 0, 1, 2, 0, 1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Take(streams.Cycle(streams.Range(0, 0)), 3); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestOfSingleEmpty(t *testing.T) {
+	if got, expected := streams.Collect(streams.Of(1, 2, 3)), []int{1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.Single("x")), []string{"x"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Collect(streams.Empty[int]()); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+
+	chained := streams.Collect(streams.Chain(streams.Empty[int](), streams.Of(1, 2), streams.Empty[int]()))
+	if expected := []int{1, 2}; !reflect.DeepEqual(chained, expected) {
+		t.Fatalf("expected %v, got %v", expected, chained)
+	}
+	if got := streams.Collect(streams.Zip(streams.Of(1, 2), streams.Empty[int]())); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestStepBy(t *testing.T) {
+	if got, expected := streams.Collect(streams.StepBy(streams.Range(0, 10), 3)), []int{0, 3, 6, 9}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.StepBy(streams.Range(0, 4), 1)), []int{0, 1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for step 0")
+		}
+	}()
+	streams.StepBy(streams.Range(0, 4), 0)
+}
+
+func TestIntersperse(t *testing.T) {
+	cases := []struct {
+		in       []string
+		expected []string
+	}{
+		{[]string{}, []string{}},
+		{[]string{"a"}, []string{"a"}},
+		{[]string{"a", "b", "c"}, []string{"a", ",", "b", ",", "c"}},
+	}
+	for _, c := range cases {
+		got := streams.Collect(streams.Intersperse(streams.Elements(c.in), ","))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("expected %v, got %v", c.expected, got)
+		}
+	}
+}
+
+func TestToMap(t *testing.T) {
+	got := streams.ToMap(streams.Elements([]string{"a", "bb", "cc", "ddd"}), func(s string) (int, string) {
+		return len(s), s
+	})
+	if expected := map[int]string{1: "a", 2: "cc", 3: "ddd"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	pairs := streams.PairsToMap(streams.Zip(streams.Elements([]string{"x", "y", "x"}), streams.Iota()))
+	if expected := map[string]int{"x": 2, "y": 1}; !reflect.DeepEqual(pairs, expected) {
+		t.Fatalf("expected %v, got %v", expected, pairs)
+	}
+}
+
+func TestKeysValuesEntries(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2, "c": 3}
+
+	keys := streams.Collect(streams.Sorted(streams.Keys(m), func(a, b string) bool { return a < b }))
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+	values := streams.Collect(streams.Sorted(streams.Values(m), func(a, b int) bool { return a < b }))
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+	if entries := streams.PairsToMap(streams.Entries(m)); !reflect.DeepEqual(entries, m) {
+		t.Fatalf("expected %v, got %v", m, entries)
+	}
+
+	snapshot := map[string]int{"a": 1, "b": 2, "c": 3}
+	entries, vals := streams.Entries(m), streams.Values(m)
+	delete(m, "b")
+	m["a"] = 100
+	if got := streams.PairsToMap(entries); !reflect.DeepEqual(got, snapshot) {
+		t.Fatalf("expected snapshot %v, got %v", snapshot, got)
+	}
+	if got := streams.Sum(vals); got != 6 {
+		t.Fatalf("expected snapshot values to sum to %v, got %v", 6, got)
+	}
+}
+
+func TestPeekable(t *testing.T) {
+	p := streams.NewPeekable(streams.Range(0, 3))
+	for i := 0; i < 3; i++ {
+		if val, ok := p.Peek(); !ok || val != 0 {
+			t.Fatalf("expected Peek to return 0, got %v (ok=%v)", val, ok)
+		}
+	}
+	if val, ok := p.Next(); !ok || val != 0 {
+		t.Fatalf("expected Next to return 0, got %v (ok=%v)", val, ok)
+	}
+	if val, ok := p.Peek(); !ok || val != 1 {
+		t.Fatalf("expected Peek to return 1, got %v (ok=%v)", val, ok)
+	}
+	rest := streams.Collect(p.Next)
+	if expected := []int{1, 2}; !reflect.DeepEqual(rest, expected) {
+		t.Fatalf("expected %v, got %v", expected, rest)
+	}
+	if _, ok := p.Peek(); ok {
+		t.Fatalf("expected Peek on exhausted stream to fail")
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestLines(t *testing.T) {
+	lines, err := streams.Lines(strings.NewReader("one\ntwo\r\n\nthree"))
+	got := streams.Collect(lines)
+	if expected := []string{"one", "two", "", "three"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if err() != nil {
+		t.Fatalf("unexpected error: %v", err())
+	}
+
+	lines, err = streams.Lines(errReader{})
+	if got := streams.Collect(lines); len(got) != 0 {
+		t.Fatalf("expected empty, got %q", got)
+	}
+	if err() == nil {
+		t.Fatalf("expected read error")
+	}
+}
+
+func TestFromScanner(t *testing.T) {
+	sc := bufio.NewScanner(strings.NewReader("the quick  brown\nfox "))
+	sc.Split(bufio.ScanWords)
+	words, err := streams.FromScanner(sc, func(b []byte) string { return string(b) })
+	got := streams.Collect(words)
+	if expected := []string{"the", "quick", "brown", "fox"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if err() != nil {
+		t.Fatalf("unexpected error: %v", err())
+	}
+
+	sc = bufio.NewScanner(strings.NewReader("héllo"))
+	sc.Split(bufio.ScanRunes)
+	runes, _ := streams.FromScanner(sc, func(b []byte) rune { return []rune(string(b))[0] })
+	if got := string(streams.Collect(runes)); got != "héllo" {
+		t.Fatalf("expected %q, got %q", "héllo", got)
+	}
+}
+
+func TestRunesBytes(t *testing.T) {
+	const s = "héllo, 世界 🎉"
+	if got := streams.Collect(streams.Runes(s)); !reflect.DeepEqual(got, []rune(s)) {
+		t.Fatalf("expected %q, got %q", []rune(s), got)
+	}
+	if got := streams.Collect(streams.Bytes(s)); !reflect.DeepEqual(got, []byte(s)) {
+		t.Fatalf("expected %v, got %v", []byte(s), got)
+	}
+	if got := streams.Collect(streams.Runes("")); len(got) != 0 {
+		t.Fatalf("expected empty, got %q", got)
+	}
+	if got := streams.Collect(streams.Bytes("")); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestSend(t *testing.T) {
+	c := make(chan int, 5)
+	streams.Send(streams.Range(0, 3), c)
+	streams.SendClose(streams.Range(3, 5), c)
+	if got, expected := streams.Collect(streams.Recieve(c)), []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestRecieveContext(t *testing.T) {
+	c := make(chan int, 2)
+	c <- 1
+	c <- 2
+	ctx, cancel := context.WithCancel(context.Background())
+	s := streams.RecieveContext(ctx, c)
+	if got, expected := streams.Take(s, 2), []int{1, 2}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	finished := make(chan []int)
+	go func() { finished <- streams.Collect(s) }()
+	cancel()
+	if got := <-finished; len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type item struct {
+		Name string
+		N    int
+	}
+	expected := []item{{"a", 1}, {"b", 2}, {"c", 3}}
+	inputs := []string{
+		`{"Name":"a","N":1}
+{"Name":"b","N":2}
+{"Name":"c","N":3}`,
+		` [{"Name":"a","N":1}, {"Name":"b","N":2}, {"Name":"c","N":3}] `,
+	}
+	for _, in := range inputs {
+		items, err := streams.DecodeJSON[item](strings.NewReader(in))
+		if got := streams.Collect(items); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("expected %v, got %v", expected, got)
+		}
+		if err() != nil {
+			t.Fatalf("unexpected error: %v", err())
+		}
+	}
+
+	items, err := streams.DecodeJSON[item](strings.NewReader(`{"Name":"a","N":1} {"Name":`))
+	if got := streams.Collect(items); !reflect.DeepEqual(got, expected[:1]) {
+		t.Fatalf("expected %v, got %v", expected[:1], got)
+	}
+	if err() == nil {
+		t.Fatalf("expected decode error")
+	}
+
+	empty, err := streams.DecodeJSON[item](strings.NewReader(""))
+	if got := streams.Collect(empty); len(got) != 0 || err() != nil {
+		t.Fatalf("expected empty stream without error, got %v, %v", got, err())
+	}
+}
+
+func TestDecodeCSV(t *testing.T) {
+	records, err := streams.DecodeCSV(strings.NewReader("name,quote\nann,\"hello, world\"\nbob,\"say \"\"hi\"\"\"\n"))
+	got := streams.Collect(records)
+	expected := [][]string{{"name", "quote"}, {"ann", "hello, world"}, {"bob", `say "hi"`}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if err() != nil {
+		t.Fatalf("unexpected error: %v", err())
+	}
+
+	cr := csv.NewReader(strings.NewReader("a;b\nc;d\ne\n"))
+	cr.Comma = ';'
+	records, err = streams.FromCSV(cr)
+	got = streams.Collect(records)
+	if expected := [][]string{{"a", "b"}, {"c", "d"}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+	if err() == nil {
+		t.Fatalf("expected field count error")
+	}
+}
+
+// concurrency wraps f so that *peak records the most calls to f that were in
+// flight at once.
+func concurrency[A, B any](f func(A) B, peak *int32) func(A) B {
+	var active int32
+	return func(a A) B {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			old := atomic.LoadInt32(peak)
+			if n <= old || atomic.CompareAndSwapInt32(peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		defer atomic.AddInt32(&active, -1)
+		return f(a)
+	}
+}
+
+func TestParMap(t *testing.T) {
+	square := func(x int) int { return x * x }
+	var peak int32
+	got := streams.Collect(streams.ParMap(streams.Range(0, 20), 4, concurrency(square, &peak)))
+	expected := streams.Collect(streams.Map(streams.Range(0, 20), square))
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if peak < 2 || peak > 4 {
+		t.Fatalf("expected between 2 and 4 concurrent calls, got %v", peak)
+	}
+
+	first := streams.Take(streams.ParMap(streams.Iota(), 4, square), 3)
+	if expected := []int{0, 1, 4}; !reflect.DeepEqual(first, expected) {
+		t.Fatalf("expected %v, got %v", expected, first)
+	}
+}
+
+func TestForEachPar(t *testing.T) {
+	var total, peak int32
+	add := concurrency(func(x int) struct{} {
+		atomic.AddInt32(&total, int32(x))
+		return struct{}{}
+	}, &peak)
+	streams.ForEachPar(streams.Range(0, 100), 8, func(x int) { add(x) })
+	if total != 4950 {
+		t.Fatalf("expected %v, got %v", 4950, total)
+	}
+	if peak < 2 || peak > 8 {
+		t.Fatalf("expected between 2 and 8 concurrent calls, got %v", peak)
+	}
+}
+
+func TestTryMap(t *testing.T) {
+	got, err := streams.CollectErr(streams.TryMap(streams.Of("1", "2", "3"), strconv.Atoi))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	pulls := 0
+	got, err = streams.CollectErr(streams.TryMap(counting(streams.Of("1", "x", "3"), &pulls), strconv.Atoi))
+	if err == nil {
+		t.Fatalf("expected error, got %v", got)
+	}
+	if pulls != 2 {
+		t.Fatalf("expected CollectErr to stop after 2 pulls, got %v", pulls)
+	}
+}
+
+func TestCatch(t *testing.T) {
+	parse := func() streams.Stream[streams.Result[int]] {
+		return streams.TryMap(streams.Of("1", "x", "3", "y"), strconv.Atoi)
+	}
+	got := streams.Collect(streams.Catch(parse(), func(error) (int, bool) { return -1, true }))
+	if expected := []int{1, -1, 3, -1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	got = streams.Collect(streams.Catch(parse(), func(error) (int, bool) { return 0, false }))
+	if expected := []int{1, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	flaky := func(failures int) func() streams.Stream[streams.Result[int]] {
+		calls := 0
+		return func() streams.Stream[streams.Result[int]] {
+			calls++
+			fail := calls <= failures
+			return streams.TryMap(streams.Range(0, 3), func(x int) (int, error) {
+				if fail && x == 2 {
+					return 0, errors.New("connection reset")
+				}
+				return x, nil
+			})
+		}
+	}
+
+	got, err := streams.CollectErr(streams.Retry(3, flaky(2)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []int{0, 1, 0, 1, 0, 1, 2}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	results := streams.Collect(streams.Retry(2, flaky(2)))
+	if len(results) != 5 || results[4].Err == nil {
+		t.Fatalf("expected 4 values followed by an error, got %v", results)
+	}
+}
+
+func TestSeq(t *testing.T) {
+	var got []int
+	for v := range streams.ToSeq(streams.Range(0, 10)) {
+		if v == 5 {
+			break
+		}
+		got = append(got, v)
+	}
+	if expected := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	round_trip := streams.Collect(streams.FromSeq(streams.ToSeq(streams.Range(0, 5))))
+	if expected := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(round_trip, expected) {
+		t.Fatalf("expected %v, got %v", expected, round_trip)
+	}
+
+	values := slices.Collect(streams.ToSeq(streams.FromSeq(slices.Values([]string{"a", "b"}))))
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(values, expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+}
+
+func TestSeq2(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2, "c": 3}
+	if got := maps.Collect(streams.ToSeq2(streams.Entries(m))); !reflect.DeepEqual(got, m) {
+		t.Fatalf("expected %v, got %v", m, got)
+	}
+	if got := streams.PairsToMap(streams.FromSeq2(maps.All(m))); !reflect.DeepEqual(got, m) {
+		t.Fatalf("expected %v, got %v", m, got)
+	}
+
+	var keys []int
+	for i, s := range streams.ToSeq2(streams.Zip(streams.Iota(), streams.Of("x", "y", "z"))) {
+		if s == "z" {
+			break
+		}
+		keys = append(keys, i)
+	}
+	if expected := []int{0, 1}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+}
+
+func TestMapIndexed(t *testing.T) {
+	labels := streams.MapIndexed(streams.Of("a", "b", "c"), func(i int, s string) string {
+		return fmt.Sprintf("%d:%s", i, s)
+	})
+	if got := strings.Join(streams.Collect(labels), " "); got != "0:a 1:b 2:c" {
+		t.Fatalf("expected %q, got %q", "0:a 1:b 2:c", got)
+	}
+}
+
+func TestFilterIndexed(t *testing.T) {
+	got := streams.Collect(streams.FilterIndexed(streams.Range(10, 20), func(i int, _ int) bool {
+		return i%2 == 0
+	}))
+	if expected := []int{10, 12, 14, 16, 18}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	got = streams.Collect(streams.FilterIndexed(streams.Range(0, 10), func(i int, x int) bool {
+		return x%3 == 0 && i < 5
+	}))
+	if expected := []int{0, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestForEachReduceIndexed(t *testing.T) {
+	var indices []int
+	streams.ForEachIndexed(streams.Of("a", "b", "c"), func(i int, _ string) {
+		indices = append(indices, i)
+	})
+	if expected := []int{0, 1, 2}; !reflect.DeepEqual(indices, expected) {
+		t.Fatalf("expected %v, got %v", expected, indices)
+	}
+
+	weighted := streams.ReduceIndexed(streams.Of(5, 5, 5), 0, func(i int, acc int, x int) int {
+		return acc + i*x
+	})
+	if weighted != 15 {
+		t.Fatalf("expected %v, got %v", 15, weighted)
+	}
+}
+
+func TestPairwise(t *testing.T) {
+	got := streams.Collect(streams.Pairwise(streams.Range(0, 4)))
+	expected := []streams.Pair[int, int]{{0, 1}, {1, 2}, {2, 3}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Collect(streams.Pairwise(streams.Of(1))); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+	if got := streams.Collect(streams.Pairwise(streams.Empty[int]())); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+
+	deltas := streams.Collect(streams.Differences(streams.Of(1, 4, 9, 16)))
+	if expected := []int{3, 5, 7}; !reflect.DeepEqual(deltas, expected) {
+		t.Fatalf("expected %v, got %v", expected, deltas)
+	}
+}
+
+func TestCartesianProduct(t *testing.T) {
+	got := streams.Collect(streams.CartesianProduct(streams.Range(0, 3), streams.Of("x", "y")))
+	expected := []streams.Pair[int, string]{{0, "x"}, {0, "y"}, {1, "x"}, {1, "y"}, {2, "x"}, {2, "y"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Collect(streams.CartesianProduct(streams.Range(0, 3), streams.Empty[string]())); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestRangeStep(t *testing.T) {
+	cases := []struct {
+		start, stop, step int
+		expected          []int
+	}{
+		{0, 10, 2, []int{0, 2, 4, 6, 8}},
+		{0, 9, 2, []int{0, 2, 4, 6, 8}},
+		{10, 0, -3, []int{10, 7, 4, 1}},
+		{0, 10, -1, []int{}},
+		{3, 3, 1, []int{}},
+	}
+	for _, c := range cases {
+		got := streams.Collect(streams.RangeStep(c.start, c.stop, c.step))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("RangeStep(%v, %v, %v): expected %v, got %v", c.start, c.stop, c.step, c.expected, got)
+		}
+	}
+
+	if got, expected := streams.Collect(streams.RangeStep[int8](120, 127, 5)), []int8{120, 125}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.RangeStep[int8](-120, -128, -5)), []int8{-120, -125}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.RangeStep[uint8](250, 255, 3)), []uint8{250, 253}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for step 0")
+		}
+	}()
+	streams.RangeStep(0, 10, 0)
+}
+
+func TestRangeFloat(t *testing.T) {
+	close_to := func(a, b []float64) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if math.Abs(a[i]-b[i]) > 1e-9 {
+				return false
+			}
+		}
+		return true
+	}
+	if got, expected := streams.Collect(streams.RangeFloat(0, 1, 0.25)), []float64{0, 0.25, 0.5, 0.75}; !close_to(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.RangeFloat(1, 0, -0.5)), []float64{1, 0.5}; !close_to(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Count(streams.RangeFloat(0, 1, 0.1)); got != 10 {
+		t.Fatalf("expected %v values, got %v", 10, got)
+	}
+}
+
+func TestIterateUnfold(t *testing.T) {
+	powers := streams.Take(streams.Iterate(1, func(x int) int { return x * 2 }), 6)
+	if expected := []int{1, 2, 4, 8, 16, 32}; !reflect.DeepEqual(powers, expected) {
+		t.Fatalf("expected %v, got %v", expected, powers)
+	}
+
+	calls := 0
+	streams.Take(streams.Iterate(0, func(x int) int {
+		calls++
+		return x + 1
+	}), 3)
+	if calls != 2 {
+		t.Fatalf("expected next to be called 2 times, got %v", calls)
+	}
+
+	type node struct{ parent *node }
+	leaf := &node{parent: &node{}}
+	chain := streams.TakeWhile(streams.Iterate(leaf, func(n *node) *node { return n.parent }), func(n *node) bool { return n != nil })
+	if got := streams.Count(chain); got != 2 {
+		t.Fatalf("expected 2, got %v", got)
+	}
+
+	fib := streams.Unfold(streams.Pair[int, int]{0, 1}, func(p streams.Pair[int, int]) (int, streams.Pair[int, int], bool) {
+		return p.First, streams.Pair[int, int]{p.Second, p.First + p.Second}, p.First < 50
+	})
+	if got, expected := streams.Collect(fib), []int{0, 1, 1, 2, 3, 5, 8, 13, 21, 34}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	pulls := 0
+	m := streams.Memoize(counting(streams.Range(0, 5), &pulls))
+	partial := m()
+	if got, expected := streams.Take(partial, 2), []int{0, 1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	expected := []int{0, 1, 2, 3, 4}
+	if got := streams.Collect(m()); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected replay %v, got %v", expected, got)
+	}
+	if got := streams.Collect(m()); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected replay %v, got %v", expected, got)
+	}
+	if got, rest := streams.Collect(partial), []int{2, 3, 4}; !reflect.DeepEqual(got, rest) {
+		t.Fatalf("expected partial stream to resume with %v, got %v", rest, got)
+	}
+	if _, has_val := partial(); has_val {
+		t.Fatalf("expected finished stream to stay done")
+	}
+	if pulls != 6 {
+		t.Fatalf("expected source to be pulled 6 times, got %v", pulls)
+	}
+}
+
+func TestTakeLastDropLast(t *testing.T) {
+	cases := []struct {
+		n          int
+		take, drop []int
+	}{
+		{3, []int{7, 8, 9}, []int{0, 1, 2, 3, 4, 5, 6}},
+		{0, []int{}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{10, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []int{}},
+		{15, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []int{}},
+		{math.MaxInt, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, []int{}},
+	}
+	for _, c := range cases {
+		if got := streams.TakeLast(streams.Range(0, 10), c.n); !reflect.DeepEqual(got, c.take) {
+			t.Fatalf("TakeLast(%v): expected %v, got %v", c.n, c.take, got)
+		}
+		if got := streams.Collect(streams.DropLast(streams.Range(0, 10), c.n)); !reflect.DeepEqual(got, c.drop) {
+			t.Fatalf("DropLast(%v): expected %v, got %v", c.n, c.drop, got)
+		}
+	}
+}
+
+func TestSplitOn(t *testing.T) {
+	split := func(s string) []string {
+		chunks := streams.SplitOn(streams.Runes(s), func(r rune) bool { return r == ' ' })
+		return streams.Collect(streams.Map(chunks, func(rs []rune) string { return string(rs) }))
+	}
+	cases := []struct {
+		in       string
+		expected []string
+	}{
+		{"the quick brown fox", []string{"the", "quick", "brown", "fox"}},
+		{"a  b ", []string{"a", "", "b", ""}},
+		{" a", []string{"", "a"}},
+		{"", []string{}},
+	}
+	for _, c := range cases {
+		if got := split(c.in); !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("%q: expected %q, got %q", c.in, c.expected, got)
+		}
+	}
+}
+
+func TestChunkBy(t *testing.T) {
+	got := streams.Collect(streams.ChunkBy(streams.Of(1, 1, 2, 2, 2, 1), func(x int) int { return x }))
+	if expected := [][]int{{1, 1}, {2, 2, 2}, {1}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	words := streams.ChunkBy(streams.Of("apple", "avocado", "banana", "blueberry", "apricot"), func(s string) byte {
+		return s[0]
+	})
+	expected := [][]string{{"apple", "avocado"}, {"banana", "blueberry"}, {"apricot"}}
+	if got := streams.Collect(words); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestFrequencies(t *testing.T) {
+	got := streams.Frequencies(streams.Runes("mississippi"))
+	if expected := map[rune]int{'m': 1, 'i': 4, 's': 4, 'p': 2}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Frequencies(streams.Empty[int]()); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+
+	mode, count, ok := streams.Mode(streams.Of(3, 1, 3, 2, 3, 1))
+	if !ok || mode != 3 || count != 3 {
+		t.Fatalf("expected 3 x3, got %v x%v (ok=%v)", mode, count, ok)
+	}
+	if _, _, ok := streams.Mode(streams.Empty[int]()); ok {
+		t.Fatalf("expected Mode of empty stream to fail")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	odd := func(x int) bool { return x%2 != 0 }
+	square := func(x int) int { return x * x }
+	odds := streams.Chained(streams.Range(0, 20)).Filter(odd).Skip(1)
+	got := streams.Chained(streams.Map(odds.Stream(), square)).TakeWhile(func(x int) bool {
+		return x < 100
+	}).Collect()
+	if expected := []int{9, 25, 49, 81}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	if n := streams.Chained(streams.Of(1, 2)).Chain(streams.Of(3), streams.Range(4, 6)).Count(); n != 5 {
+		t.Fatalf("expected %v, got %v", 5, n)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	if !streams.Equal(streams.Range(0, 5), streams.Of(0, 1, 2, 3, 4)) {
+		t.Fatalf("expected equal streams")
+	}
+	if !streams.Equal(streams.Empty[int](), streams.Empty[int]()) {
+		t.Fatalf("expected empty streams to be equal")
+	}
+	if streams.Equal(streams.Range(0, 3), streams.Range(0, 4)) {
+		t.Fatalf("expected prefix to differ from longer stream")
+	}
+	if streams.Equal(streams.Range(0, 4), streams.Range(0, 3)) {
+		t.Fatalf("expected longer stream to differ from prefix")
+	}
+	if streams.Equal(streams.Iota(), streams.Of(0, 1, 5)) {
+		t.Fatalf("expected differing elements to short-circuit")
+	}
+
+	fold := func(a, b string) bool { return strings.EqualFold(a, b) }
+	if !streams.EqualBy(streams.Of("a", "B"), streams.Of("A", "b"), fold) {
+		t.Fatalf("expected case-insensitive equality")
+	}
+}
+
+func TestStartsWith(t *testing.T) {
+	if !streams.StartsWith(streams.Iota(), streams.Of(0, 1, 2)) {
+		t.Fatalf("expected Iota to start with 0 1 2")
+	}
+	if !streams.StartsWith(streams.Of(1, 2), streams.Empty[int]()) {
+		t.Fatalf("expected empty prefix to match")
+	}
+	if streams.StartsWith(streams.Of(1, 2), streams.Of(1, 2, 3)) {
+		t.Fatalf("expected longer prefix not to match")
+	}
+	if streams.StartsWith(streams.Of(1, 3, 2), streams.Of(1, 2)) {
+		t.Fatalf("expected mismatched prefix not to match")
+	}
+
+	source := streams.Range(0, 10)
+	streams.StartsWith(source, streams.Of(0, 1))
+	if next, _ := source(); next != 2 {
+		t.Fatalf("expected StartsWith to consume only the prefix, next was %v", next)
+	}
+}
+
+func TestChain(t *testing.T) {
+	if got := streams.Collect(streams.Chain[int]()); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+	if got, expected := streams.Collect(streams.Chain(streams.Range(0, 3))), []int{0, 1, 2}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	got := streams.Collect(streams.Chain(streams.Range(0, 2), nil, streams.Empty[int](), streams.Range(5, 7)))
+	if expected := []int{0, 1, 5, 6}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if got := streams.Join(streams.Of("a", "b", "c"), ", "); got != "a, b, c" {
+		t.Fatalf("expected %q, got %q", "a, b, c", got)
+	}
+	if got := streams.Join(streams.Empty[string](), ", "); got != "" {
+		t.Fatalf("expected empty string, got %q", got)
+	}
+	if got := streams.JoinRunes(streams.Range('a', 'f')); got != "abcde" {
+		t.Fatalf("expected %q, got %q", "abcde", got)
+	}
+	if got := streams.JoinRunes(streams.Runes("héllo 世界")); got != "héllo 世界" {
+		t.Fatalf("expected %q, got %q", "héllo 世界", got)
+	}
+}
+
+func TestReduceControl(t *testing.T) {
+	const limit = 20
+	pulls := 0
+	sum := streams.ReduceControl(counting(streams.Iota(), &pulls), 0, func(acc, x int) (int, streams.Control) {
+		acc += x
+		if acc > limit {
+			return acc, streams.Break
+		}
+		return acc, streams.Continue
+	})
+	if sum != 21 {
+		t.Fatalf("expected %v, got %v", 21, sum)
+	}
+	if pulls != 7 {
+		t.Fatalf("expected 7 pulls, got %v", pulls)
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	buffered, stop := streams.Buffer(streams.Range(0, 100), 10)
+	defer stop()
+	if got, expected := streams.Collect(buffered), streams.Collect(streams.Range(0, 100)); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	var pulls int32
+	infinite, stop := streams.Buffer(func() (int32, bool) {
+		return atomic.AddInt32(&pulls, 1), true
+	}, 4)
+	if got, expected := streams.Take(infinite, 3), []int32{1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	stop()
+	if _, has_val := infinite(); has_val {
+		t.Fatalf("expected stopped buffer to be done")
+	}
+	time.Sleep(10 * time.Millisecond)
+	before := atomic.LoadInt32(&pulls)
+	time.Sleep(20 * time.Millisecond)
+	if after := atomic.LoadInt32(&pulls); after != before {
+		t.Fatalf("expected producer to stop, pulls went from %v to %v", before, after)
+	}
+
+	unbuffered, stop := streams.Buffer(streams.Range(0, 5), 0)
+	defer stop()
+	if got, expected := streams.Collect(unbuffered), []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for size -1")
+		}
+	}()
+	streams.Buffer(streams.Range(0, 5), -1)
+}
+
+func TestThrottle(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	var times []time.Time
+	start := time.Now()
+	streams.ForEach(streams.Throttle(streams.Range(0, 4), interval), func(int) {
+		times = append(times, time.Now())
+	})
+	if len(times) != 4 {
+		t.Fatalf("expected 4 elements, got %v", len(times))
+	}
+	if first := times[0].Sub(start); first > interval/2 {
+		t.Fatalf("expected first element immediately, took %v", first)
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < interval {
+			t.Fatalf("expected at least %v between elements, got %v", interval, gap)
+		}
+	}
+}
+
+func TestSpan(t *testing.T) {
+	prefix, rest := streams.Span(streams.Range(0, 10), func(x int) bool { return x < 4 })
+	if expected := []int{0, 1, 2, 3}; !reflect.DeepEqual(prefix, expected) {
+		t.Fatalf("expected %v, got %v", expected, prefix)
+	}
+	if got, expected := streams.Collect(rest), []int{4, 5, 6, 7, 8, 9}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	prefix, rest = streams.Span(streams.Range(0, 3), func(int) bool { return true })
+	if expected := []int{0, 1, 2}; !reflect.DeepEqual(prefix, expected) {
+		t.Fatalf("expected %v, got %v", expected, prefix)
+	}
+	if got := streams.Collect(rest); len(got) != 0 {
+		t.Fatalf("expected empty rest, got %v", got)
+	}
+}
+
+func TestTopN(t *testing.T) {
+	less := func(a, b int) bool { return a < b }
+	const n = 100000
+	shuffled := func() streams.Stream[int] {
+		return streams.Map(streams.Range(0, n), func(x int) int { return (x * 7919) % n })
+	}
+	sorted := streams.Collect(streams.Sorted(shuffled(), less))
+
+	top := streams.TopN(shuffled(), 5, less)
+	if expected := []int{sorted[n-1], sorted[n-2], sorted[n-3], sorted[n-4], sorted[n-5]}; !reflect.DeepEqual(top, expected) {
+		t.Fatalf("expected %v, got %v", expected, top)
+	}
+	bottom := streams.BottomN(shuffled(), 5, less)
+	if expected := sorted[:5]; !reflect.DeepEqual(bottom, expected) {
+		t.Fatalf("expected %v, got %v", expected, bottom)
+	}
+	if got, expected := streams.TopN(streams.Of(2, 1, 3), 10, less), []int{3, 2, 1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.BottomN(streams.Of(2, 1, 3), math.MaxInt, less), []int{1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	allocs := testing.AllocsPerRun(5, func() {
+		streams.TopN(shuffled(), 5, less)
+	})
+	if allocs > 50 {
+		t.Fatalf("expected allocations bounded by n, got %v", allocs)
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	values := []float64{1, 3, 2, 8, 5, 5, 0, 4, 7}
+	for _, window := range []int{1, 3, 9, 10, math.MaxInt} {
+		got := streams.Collect(streams.MovingAverage(streams.Elements(values), window))
+		expected := []float64{}
+		if window <= len(values) {
+			expected = streams.Collect(streams.Map(streams.Window(streams.Elements(values), window), func(w []float64) float64 {
+				return streams.Sum(streams.Elements(w)) / float64(window)
+			}))
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("window %v: expected %v, got %v", window, expected, got)
+		}
+		for i := range got {
+			if math.Abs(got[i]-expected[i]) > 1e-9 {
+				t.Fatalf("window %v: expected %v, got %v", window, expected, got)
+			}
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	if got, expected := streams.Collect(streams.Compact(streams.Of("a", "", "b", "", ""))), []string{"a", "b"}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.Compact(streams.Of(0, 1, 0, 2))), []int{1, 2}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestTap(t *testing.T) {
+	var seen []int
+	tapped := streams.Tap(streams.Range(0, 10), func(x int) { seen = append(seen, x) })
+	squares := streams.Map(tapped, func(x int) int { return x * x })
+	if len(seen) != 0 {
+		t.Fatalf("expected Tap to be lazy, saw %v", seen)
+	}
+	if got, expected := streams.Take(squares, 3), []int{0, 1, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if expected := []int{0, 1, 2}; !reflect.DeepEqual(seen, expected) {
+		t.Fatalf("expected callbacks for %v, got %v", expected, seen)
+	}
+}
+
+func TestDefaultIfEmpty(t *testing.T) {
+	if got, expected := streams.Collect(streams.DefaultIfEmpty(streams.Range(1, 4), -1)), []int{1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.DefaultIfEmpty(streams.Empty[int](), -1)), []int{-1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestPrependAppend(t *testing.T) {
+	got := streams.Collect(streams.Append(streams.Prepend(streams.Range(0, 3), -2, -1), 3, 4))
+	if expected := []int{-2, -1, 0, 1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.Prepend(streams.Range(0, 2))), []int{0, 1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestWithIndex(t *testing.T) {
+	for _, iv := range streams.Take(streams.WithIndex(streams.Iota()), 5) {
+		if iv.Index != iv.Value {
+			t.Fatalf("expected Index == Value, got %v", iv)
+		}
+	}
+	got := streams.Collect(streams.WithIndex(streams.Of("a", "b")))
+	if expected := []streams.IndexedValue[string]{{0, "a"}, {1, "b"}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestCollectInto(t *testing.T) {
+	dst := make([]int, 1, 10)
+	got := streams.CollectInto(streams.Range(1, 4), dst)
+	if expected := []int{0, 1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if &got[0] != &dst[0] {
+		t.Fatalf("expected CollectInto to reuse dst's backing array")
+	}
+
+	if got, expected := streams.CollectN(streams.Range(0, 3), 10), []int{0, 1, 2}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		streams.CollectN(streams.Range(0, 1000), 1000)
+	})
+	if growing := testing.AllocsPerRun(10, func() {
+		streams.Collect(streams.Range(0, 1000))
+	}); allocs >= growing {
+		t.Fatalf("expected CollectN to allocate less than Collect, got %v vs %v", allocs, growing)
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		streams.Collect(streams.Range(0, 1000))
+	}
+}
+
+func BenchmarkCollectN(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		streams.CollectN(streams.Range(0, 1000), 1000)
+	}
+}
+
+func BenchmarkCollectInto(b *testing.B) {
+	dst := make([]int, 0, 1000)
+	for i := 0; i < b.N; i++ {
+		dst = streams.CollectInto(streams.Range(0, 1000), dst[:0])
+	}
+}
+
+func TestCollectChan(t *testing.T) {
+	var got []int
+	for val := range streams.CollectChan(streams.Range(0, 5)) {
+		got = append(got, val)
+	}
+	if expected := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := streams.CollectChanContext(ctx, streams.Iota())
+	if first := <-c; first != 0 {
+		t.Fatalf("expected %v, got %v", 0, first)
+	}
+	cancel()
+	for range c {
+	}
+}
+
+func TestStats(t *testing.T) {
+	got := streams.Stats(streams.Of(2.0, 4, 4, 4, 5, 5, 7, 9))
+	expected := streams.StatsResult[float64]{Count: 8, Sum: 40, Mean: 5, Min: 2, Max: 9, Variance: 4}
+	if got.Count != expected.Count || got.Min != expected.Min || got.Max != expected.Max ||
+		math.Abs(got.Sum-expected.Sum) > 1e-9 || math.Abs(got.Mean-expected.Mean) > 1e-9 ||
+		math.Abs(got.Variance-expected.Variance) > 1e-9 {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+
+	shifted := streams.Stats(streams.Map(streams.Of(2.0, 4, 4, 4, 5, 5, 7, 9), func(x float64) float64 {
+		return x + 1e9
+	}))
+	if math.Abs(shifted.Variance-4) > 1e-6 {
+		t.Fatalf("expected variance %v for shifted data, got %v", 4, shifted.Variance)
+	}
+
+	if empty := streams.Stats(streams.Empty[float64]()); empty != (streams.StatsResult[float64]{}) {
+		t.Fatalf("expected zero result, got %+v", empty)
+	}
+}
+
+func TestReduceRight(t *testing.T) {
+	got := streams.ReduceRight(streams.Range(0, 4), []int{}, func(x int, acc []int) []int {
+		return append([]int{x}, acc...)
+	})
+	if expected := []int{0, 1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	nested := streams.ReduceRight(streams.Of("a", "b", "c"), "", func(s string, acc string) string {
+		return "(" + s + acc + ")"
+	})
+	if nested != "(a(b(c)))" {
+		t.Fatalf("expected %q, got %q", "(a(b(c)))", nested)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	cases := []struct {
+		start, end int
+		expected   []int
+	}{
+		{5, 10, []int{5, 6, 7, 8, 9}},
+		{0, 3, []int{0, 1, 2}},
+		{-2, 2, []int{0, 1}},
+		{18, 30, []int{18, 19}},
+		{10, 10, []int{}},
+		{10, 5, []int{}},
+	}
+	for _, c := range cases {
+		got := streams.Collect(streams.Slice(streams.Range(0, 20), c.start, c.end))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("Slice(%v, %v): expected %v, got %v", c.start, c.end, c.expected, got)
+		}
+	}
+}
+
+func TestTakeStream(t *testing.T) {
+	pulls := 0
+	squares := streams.Map(streams.TakeStream(counting(streams.Iota(), &pulls), 5), func(x int) int { return x * x })
+	if got, expected := streams.Collect(squares), []int{0, 1, 4, 9, 16}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if pulls != 5 {
+		t.Fatalf("expected 5 pulls, got %v", pulls)
+	}
+	if got, expected := streams.Collect(streams.TakeStream(streams.Range(0, 2), 5)), []int{0, 1}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Collect(streams.TakeStream(streams.Iota(), 0)); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestElementAtOr(t *testing.T) {
+	if got := streams.ElementAtOr(streams.Iota(), 7, -1); got != 7 {
+		t.Fatalf("expected %v, got %v", 7, got)
+	}
+	if got := streams.ElementAtOr(streams.Range(0, 3), 3, -1); got != -1 {
+		t.Fatalf("expected %v, got %v", -1, got)
+	}
+	if got := streams.ElementAtOr(streams.Range(0, 3), -1, -1); got != -1 {
+		t.Fatalf("expected %v, got %v", -1, got)
+	}
+}
+
+func TestFlattenSlices(t *testing.T) {
+	got := streams.Collect(streams.FlattenSlices(streams.Of([]int{}, []int{1, 2}, nil, []int{3}, []int{})))
+	if expected := []int{1, 2, 3}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := streams.Collect(streams.FlattenSlices(streams.Chunk(streams.Range(0, 5), 2))), []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	words := streams.Collect(streams.FlatMapSlice(streams.Of("a b", "", "c"), strings.Fields))
+	if expected := []string{"a", "b", "c"}; !reflect.DeepEqual(words, expected) {
+		t.Fatalf("expected %v, got %v", expected, words)
+	}
+}
+
+func TestFilterNot(t *testing.T) {
+	div3 := func(x int) bool { return x%3 == 0 }
+	kept := streams.Collect(streams.Filter(streams.Range(0, 10), div3))
+	dropped := streams.Collect(streams.FilterNot(streams.Range(0, 10), div3))
+	if expected := []int{1, 2, 4, 5, 7, 8}; !reflect.DeepEqual(dropped, expected) {
+		t.Fatalf("expected %v, got %v", expected, dropped)
+	}
+	all := streams.Collect(streams.Sorted(streams.Chain(streams.Elements(kept), streams.Elements(dropped)), func(a, b int) bool {
+		return a < b
+	}))
+	if expected := streams.Collect(streams.Range(0, 10)); !reflect.DeepEqual(all, expected) {
+		t.Fatalf("expected Filter and FilterNot to partition %v, got %v", expected, all)
+	}
+}
+
+func TestParFilter(t *testing.T) {
+	prime := func(x int) bool {
+		if x < 2 {
+			return false
+		}
+		for d := 2; d*d <= x; d++ {
+			if x%d == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	var peak int32
+	got := streams.Collect(streams.ParFilter(streams.Range(0, 50), 4, concurrency(prime, &peak)))
+	expected := streams.Collect(streams.Filter(streams.Range(0, 50), prime))
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if peak < 2 || peak > 4 {
+		t.Fatalf("expected between 2 and 4 concurrent calls, got %v", peak)
+	}
+}
+
+func TestParReduce(t *testing.T) {
+	add := func(a, b int) int { return a + b }
+	const n = 1000000
+	if got, expected := streams.ParReduce(streams.Range(0, n), 8, 0, add), streams.Reduce(streams.Range(0, n), 0, add); got != expected {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.ParReduce(streams.Empty[int](), 8, 0, add); got != 0 {
+		t.Fatalf("expected %v, got %v", 0, got)
+	}
+
+	concat := func(a, b string) string { return a + b }
+	words := streams.Collect(streams.Map(streams.Range(0, 5000), strconv.Itoa))
+	if got, expected := streams.ParReduce(streams.Elements(words), 4, "", concat), strings.Join(words, ""); got != expected {
+		t.Fatalf("expected associative but non-commutative combine to preserve order")
+	}
+}
+
+func TestSortedBy(t *testing.T) {
+	type user struct {
+		Name string
+		ID   int
+	}
+	users := []user{{"carol", 1}, {"alice", 2}, {"bob", 3}, {"alice", 4}}
+	got := streams.Collect(streams.SortedBy(streams.Elements(users), func(u user) string { return u.Name }))
+	expected := []user{{"alice", 2}, {"alice", 4}, {"bob", 3}, {"carol", 1}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestSample(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	if got := streams.Count(streams.Sample(streams.Range(0, 100), 1, rng)); got != 100 {
+		t.Fatalf("expected %v, got %v", 100, got)
+	}
+	if got := streams.Count(streams.Sample(streams.Range(0, 100), 0, rng)); got != 0 {
+		t.Fatalf("expected %v, got %v", 0, got)
+	}
+
+	first := streams.Collect(streams.Sample(streams.Range(0, 100), 0.5, rand.New(rand.NewSource(42))))
+	second := streams.Collect(streams.Sample(streams.Range(0, 100), 0.5, rand.New(rand.NewSource(42))))
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("expected identical samples for the same seed, got %v and %v", first, second)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic for probability 1.5")
+		}
+	}()
+	streams.Sample(streams.Range(0, 100), 1.5, rng)
+}
+
+func TestIndexOf(t *testing.T) {
+	if got := streams.IndexOf(streams.Of("a", "b", "c", "b"), "b"); got != 1 {
+		t.Fatalf("expected %v, got %v", 1, got)
+	}
+	if got := streams.IndexOf(streams.Of("a", "b"), "z"); got != -1 {
+		t.Fatalf("expected %v, got %v", -1, got)
+	}
+	if got := streams.IndexOf(streams.Iota(), 500); got != 500 {
+		t.Fatalf("expected %v, got %v", 500, got)
+	}
+	if got := streams.Position(streams.Range(10, 20), func(x int) bool { return x%7 == 0 }); got != 4 {
+		t.Fatalf("expected %v, got %v", 4, got)
+	}
+	if got := streams.Position(streams.Range(0, 5), func(x int) bool { return x > 5 }); got != -1 {
+		t.Fatalf("expected %v, got %v", -1, got)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	sum := 0
+	n := streams.Drain(streams.Map(streams.Tap(streams.Range(0, 10), func(x int) { sum += x }), func(x int) int {
+		return x * 2
+	}))
+	if n != 10 {
+		t.Fatalf("expected %v elements, got %v", 10, n)
+	}
+	if sum != 45 {
+		t.Fatalf("expected Tap to see every element, sum was %v", sum)
+	}
+}
+
+func TestInterleave(t *testing.T) {
+	got := streams.Collect(streams.Interleave(streams.Of(1), streams.Of(10, 11), streams.Of(20, 21, 22)))
+	if expected := []int{1, 10, 20, 11, 21, 22}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestChainSep(t *testing.T) {
+	got := streams.Collect(streams.ChainSep(0, streams.Of(1, 2), streams.Of(3), streams.Of(4, 5)))
+	if expected := []int{1, 2, 0, 3, 0, 4, 5}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	got = streams.Collect(streams.ChainSep(0, streams.Empty[int](), streams.Of(1), streams.Empty[int](), nil, streams.Of(2), streams.Empty[int]()))
+	if expected := []int{1, 0, 2}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got := streams.Collect(streams.ChainSep[int](0)); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+}
+
+func TestBatchTimed(t *testing.T) {
+	batches, stop := streams.BatchTimed(streams.Range(0, 7), 3, time.Hour)
+	defer stop()
+	if got, expected := streams.Collect(batches), [][]int{{0, 1, 2}, {3, 4, 5}, {6}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	c := make(chan int)
+	go func() {
+		c <- 1
+		c <- 2
+		time.Sleep(100 * time.Millisecond)
+		c <- 3
+		close(c)
+	}()
+	batches, stop = streams.BatchTimed(streams.Recieve(c), 10, 20*time.Millisecond)
+	defer stop()
+	if got, expected := streams.Collect(batches), [][]int{{1, 2}, {3}}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	chans := make([]chan int, 3)
+	recv := make([]<-chan int, 3)
+	for i := range chans {
+		chans[i] = make(chan int)
+		recv[i] = chans[i]
+		go streams.SendClose(streams.Range(i*100, i*100+50), chans[i])
+	}
+	got := streams.Collect(streams.Select(recv...))
+	per_chan := streams.GroupBy(streams.Elements(got), func(x int) int { return x / 100 })
+	for i := range chans {
+		if expected := streams.Collect(streams.Range(i*100, i*100+50)); !reflect.DeepEqual(per_chan[i], expected) {
+			t.Fatalf("channel %v: expected %v, got %v", i, expected, per_chan[i])
+		}
+	}
+	if got := streams.Collect(streams.Select[int]()); len(got) != 0 {
+		t.Fatalf("expected empty, got %v", got)
+	}
+
+	errs := make(chan error, 2)
+	errs <- nil
+	errs <- errors.New("boom")
+	close(errs)
+	got_errs := streams.Collect(streams.Select[error](errs))
+	if len(got_errs) != 2 || got_errs[0] != nil || got_errs[1] == nil {
+		t.Fatalf("expected [nil boom], got %v", got_errs)
+	}
+}
+
+func TestClone(t *testing.T) {
+	pulls := 0
+	clones := streams.Clone(counting(streams.Range(0, 10), &pulls), 3)
+	fast := streams.Take(clones[0], 10)
+	medium := streams.Take(clones[1], 5)
+	slow := streams.Take(clones[2], 1)
+	medium = append(medium, streams.Collect(clones[1])...)
+	slow = append(slow, streams.Collect(clones[2])...)
+
+	expected := streams.Collect(streams.Range(0, 10))
+	for i, got := range [][]int{fast, medium, slow} {
+		if !reflect.DeepEqual(got, expected) {
+			t.Fatalf("clone %v: expected %v, got %v", i, expected, got)
+		}
+	}
+	if _, has_val := clones[0](); has_val {
+		t.Fatalf("expected clone to be done")
+	}
+	if pulls != 11 {
+		t.Fatalf("expected source to be pulled 11 times, got %v", pulls)
+	}
+}
+
+func TestZipGraceful(t *testing.T) {
+	pulls := 0
+	a := counting(streams.Range(0, 10), &pulls)
+	b := streams.NewPeekable(streams.Of("x", "y", "z"))
+	got := streams.Collect(streams.ZipGraceful(a, b))
+	expected := []streams.Pair[int, string]{{0, "x"}, {1, "y"}, {2, "z"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if pulls != 3 {
+		t.Fatalf("expected 3 pulls from a, got %v", pulls)
+	}
+	if next, _ := a(); next != 3 {
+		t.Fatalf("expected 3 to remain in a, got %v", next)
+	}
+
+	short := streams.NewPeekable(streams.Of("x", "y", "z"))
+	pairs := streams.Collect(streams.ZipGraceful(streams.Range(0, 2), short))
+	if len(pairs) != 2 {
+		t.Fatalf("expected 2 pairs, got %v", pairs)
+	}
+	if next, _ := short.Next(); next != "z" {
+		t.Fatalf("expected z to remain in b, got %v", next)
+	}
+}
+
+func TestZipLongest(t *testing.T) {
+	got := streams.Collect(streams.ZipLongest(streams.Range(0, 3), streams.Of("a", "b", "c", "d", "e"), -1, "-"))
+	expected := []streams.Pair[int, string]{{0, "a"}, {1, "b"}, {2, "c"}, {-1, "d"}, {-1, "e"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	got = streams.Collect(streams.ZipLongest(streams.Range(0, 5), streams.Of("a", "b", "c"), -1, "-"))
+	expected = []streams.Pair[int, string]{{0, "a"}, {1, "b"}, {2, "c"}, {3, "-"}, {4, "-"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestWindowStep(t *testing.T) {
+	cases := []struct {
+		n, size, step int
+		expected      [][]int
+	}{
+		{8, 3, 2, [][]int{{0, 1, 2}, {2, 3, 4}, {4, 5, 6}}},
+		{5, 2, 2, [][]int{{0, 1}, {2, 3}}},
+		{6, 2, 2, [][]int{{0, 1}, {2, 3}, {4, 5}}},
+		{10, 2, 4, [][]int{{0, 1}, {4, 5}, {8, 9}}},
+		{4, 2, 1, [][]int{{0, 1}, {1, 2}, {2, 3}}},
+		{2, 3, 1, [][]int{}},
+		{10, math.MaxInt, 1, [][]int{}},
+	}
+	for _, c := range cases {
+		got := streams.Collect(streams.WindowStep(streams.Range(0, c.n), c.size, c.step))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Fatalf("WindowStep(Range(0, %v), %v, %v): expected %v, got %v", c.n, c.size, c.step, c.expected, got)
+		}
+	}
+}